@@ -7,12 +7,23 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/cockroachdb/apd"
 	"github.com/cyverse-de/go-mod/gotelnats"
 	"github.com/cyverse-de/go-mod/pbinit"
+	"github.com/cyverse-de/jex-adapter/audit"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/deadlines"
+	"github.com/cyverse-de/jex-adapter/jobstatus"
 	"github.com/cyverse-de/jex-adapter/logging"
 	"github.com/cyverse-de/jex-adapter/millicores"
+	"github.com/cyverse-de/jex-adapter/routing"
+	"github.com/cyverse-de/jex-adapter/scheduler"
+	"github.com/cyverse-de/jex-adapter/stopqueues"
+	"github.com/cyverse-de/jex-adapter/submissions"
 	"github.com/cyverse-de/jex-adapter/types"
 	"github.com/cyverse-de/messaging/v9"
 	"github.com/cyverse-de/model/v6"
@@ -24,6 +35,8 @@ import (
 	"github.com/spf13/viper"
 	"github.com/streadway/amqp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var log = logging.Log.WithFields(logrus.Fields{"package": "adapter"})
@@ -32,12 +45,20 @@ const otelName = "github.com/cyverse-de/jex-adapter/adapter"
 
 type Messenger interface {
 	Launch(context context.Context, job *model.Job) error
-	Stop(context context.Context, id string) error
+	Stop(context context.Context, id, reason string) error
 }
 
+// stopReason is the reason given in stop requests sent on behalf of callers.
+const stopReason = "because I said to"
+
+// amqpError exits if the error means that the connection to the broker is
+// unusable. Other errors, such as publishes that the broker didn't accept,
+// callers giving up, or failed quota checks, are left for the caller to
+// report, since the launches that run in the background, such as scheduled
+// ones, would otherwise bring down every replica.
 func amqpError(err error) {
-	amqpErr, ok := err.(*amqp.Error)
-	if !ok || amqpErr.Code != 0 {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code != 0 {
 		log.Fatal(err)
 	}
 }
@@ -46,21 +67,101 @@ type AMQPMessenger struct {
 	exchange string
 	client   *messaging.Client
 	//nolint:staticcheck // EncodedConn retirement is a planned follow-up to the protobuf removal
-	natsConn *nats.EncodedConn
+	natsConn  *nats.EncodedConn
+	publisher Publisher
+	router    *routing.Router
+}
+
+// MessengerOption configures optional features of an *AMQPMessenger.
+type MessengerOption func(*AMQPMessenger)
+
+// WithPublisher publishes launch and stop requests through the publisher
+// instead of the messaging client, so that they can be confirmed by the broker.
+func WithPublisher(p Publisher) MessengerOption {
+	return func(a *AMQPMessenger) {
+		a.publisher = p
+	}
+}
+
+// WithRouter sends launch requests to the exchanges and routing keys picked by
+// the router instead of always using messaging.LaunchesKey. Exchanges other
+// than the default one can only be used along with WithPublisher.
+func WithRouter(r *routing.Router) MessengerOption {
+	return func(a *AMQPMessenger) {
+		a.router = r
+	}
 }
 
 //nolint:staticcheck // EncodedConn retirement is a planned follow-up to the protobuf removal
-func NewAMQPMessenger(exchange string, client *messaging.Client, natsConn *nats.EncodedConn) *AMQPMessenger {
-	return &AMQPMessenger{
+func NewAMQPMessenger(exchange string, client *messaging.Client, natsConn *nats.EncodedConn, opts ...MessengerOption) *AMQPMessenger {
+	a := &AMQPMessenger{
 		exchange: exchange,
 		client:   client,
 		natsConn: natsConn,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// publish publishes a message through the publisher if there is one, or the
+// messaging client otherwise. The trace context is carried in the message
+// headers, so the job services can continue the trace. The messaging client
+// can only publish to the default exchange.
+func (a *AMQPMessenger) publish(context context.Context, exchange, key string, body []byte) (err error) {
+	ctx, span := otel.Tracer(otelName).Start(context, exchange+" publish", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	invID, submitter := logging.JobFromContext(ctx)
+	logging.SetSpanAttributes(span,
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", exchange),
+		attribute.String("messaging.rabbitmq.destination.routing_key", key),
+		attribute.String("job.external_id", invID),
+		attribute.String("job.submitter", submitter),
+	)
+	defer func() {
+		if err != nil {
+			logging.RecordSpanError(span, err)
+		}
+	}()
+
+	if a.publisher != nil {
+		return a.publisher.Publish(ctx, exchange, key, body)
+	}
+	if exchange != a.exchange {
+		return logging.NewStatusErrorResponse(
+			http.StatusInternalServerError,
+			"",
+			fmt.Sprintf("the %s exchange can only be published to with publisher confirms enabled", exchange),
+		)
+	}
+	return a.client.PublishContext(ctx, key, body)
 }
 
-func (a *AMQPMessenger) Stop(context context.Context, id string) error {
-	err := a.client.SendStopRequestContext(context, id, "root", "because I said to")
+func (a *AMQPMessenger) Stop(context context.Context, id, reason string) (err error) {
+	ctx, span := otel.Tracer(otelName).Start(logging.WithJob(context, id, ""), "Stop")
+	defer span.End()
+
+	logging.SetSpanAttributes(span, attribute.String("job.external_id", id))
+	defer func() {
+		if err != nil {
+			logging.RecordSpanError(span, err)
+		}
+	}()
+
+	req := messaging.NewStopRequest()
+	req.Username = "root"
+	req.Reason = reason
+	req.InvocationID = id
+
+	body, err := json.Marshal(req)
 	if err != nil {
+		return err
+	}
+
+	if err = a.publish(ctx, a.exchange, messaging.StopRequestKey(id), body); err != nil {
 		amqpError(err)
 	}
 	return err
@@ -103,24 +204,56 @@ func (a *AMQPMessenger) validateLaunch(ctx context.Context, job *model.Job) erro
 		}
 
 		if inOverage {
-			return fmt.Errorf("%s has resource overages", job.Submitter)
+			return logging.NewStatusErrorResponse(
+				http.StatusForbidden,
+				logging.ErrCodeForbidden,
+				fmt.Sprintf("%s has resource overages", job.Submitter),
+			)
 		}
 	}
 
 	return nil
 }
 
+// ValidateLaunch runs the checks done before a launch, such as the quota
+// check, without launching the job.
+func (a *AMQPMessenger) ValidateLaunch(context context.Context, job *model.Job) error {
+	return a.validateLaunch(context, job)
+}
+
+// DeleteStopQueue deletes the stop queue created when the job was launched.
+func (a *AMQPMessenger) DeleteStopQueue(context context.Context, invID string) error {
+	return a.client.DeleteQueue(messaging.StopQueueName(invID))
+}
+
 func (a *AMQPMessenger) Launch(context context.Context, job *model.Job) error {
 	var (
 		err        error
 		launchJSON []byte
 	)
 
-	ctx, span := otel.Tracer(otelName).Start(context, "Launch")
+	// The caller's context usually has the submitter's username as it appears
+	// in the submission. See username.
+	submitter := job.Submitter
+	if _, s := logging.JobFromContext(context); s != "" {
+		submitter = s
+	}
+
+	ctx, span := otel.Tracer(otelName).Start(logging.WithJob(context, job.InvocationID, submitter), "Launch")
 	defer span.End()
 
+	logging.SetSpanAttributes(span,
+		attribute.String("job.external_id", job.InvocationID),
+		attribute.String("job.submitter", submitter),
+		attribute.String("job.app_id", job.AppID),
+	)
+	defer func() {
+		if err != nil {
+			logging.RecordSpanError(span, err)
+		}
+	}()
+
 	if err = a.validateLaunch(ctx, job); err != nil {
-		amqpError(err)
 		return err
 	}
 
@@ -146,7 +279,16 @@ func (a *AMQPMessenger) Launch(context context.Context, job *model.Job) error {
 		return err
 	}
 
-	if err = a.client.PublishContext(ctx, messaging.LaunchesKey, launchJSON); err != nil {
+	route, rule := routing.Route{Exchange: a.exchange, RoutingKey: messaging.LaunchesKey}, ""
+	if a.router != nil {
+		route, rule = a.router.Route(job)
+	}
+	logging.SetSpanAttributes(span, attribute.String("job.route", rule))
+	if rule != "" {
+		log.WithContext(ctx).Infof("routing the job to %s on the %s exchange by the %s rule", route.RoutingKey, route.Exchange, rule)
+	}
+
+	if err = a.publish(ctx, route.Exchange, route.RoutingKey, launchJSON); err != nil {
 		amqpError(err)
 		return err
 	}
@@ -163,6 +305,13 @@ type millicoresJob struct {
 // JEXAdapter contains the application state for jex-adapter.
 type JEXAdapter struct {
 	cfg       *viper.Viper
+	db        *db.Database
+	auditor   *audit.Auditor
+	subs      *submissions.Store
+	sched     *scheduler.Store
+	deadlines *deadlines.Store
+	states    *jobstatus.Tracker
+	queues    *stopqueues.Manager
 	detector  *millicores.Detector
 	messenger Messenger
 	jobs      map[string]bool
@@ -171,9 +320,57 @@ type JEXAdapter struct {
 	exit      chan bool
 }
 
+// Option configures optional parts of a *JEXAdapter.
+type Option func(*JEXAdapter)
+
+// WithDatabase sets the database used to look up information about existing
+// jobs, such as who submitted them.
+func WithDatabase(dbase *db.Database) Option {
+	return func(j *JEXAdapter) {
+		j.db = dbase
+	}
+}
+
+// WithAuditor sets the auditor that records launch and stop requests.
+func WithAuditor(auditor *audit.Auditor) Option {
+	return func(j *JEXAdapter) {
+		j.auditor = auditor
+	}
+}
+
+// WithSubmissions sets the store that keeps a copy of each launched job
+// submission.
+func WithSubmissions(store *submissions.Store) Option {
+	return func(j *JEXAdapter) {
+		j.subs = store
+	}
+}
+
+// WithDeadlines sets the store that keeps the deadlines of running jobs.
+func WithDeadlines(store *deadlines.Store) Option {
+	return func(j *JEXAdapter) {
+		j.deadlines = store
+	}
+}
+
+// WithJobStatus sets the tracker that follows the states of launched jobs.
+func WithJobStatus(tracker *jobstatus.Tracker) Option {
+	return func(j *JEXAdapter) {
+		j.states = tracker
+	}
+}
+
+// WithStopQueues sets the manager that deletes the stop queues of finished
+// jobs.
+func WithStopQueues(manager *stopqueues.Manager) Option {
+	return func(j *JEXAdapter) {
+		j.queues = manager
+	}
+}
+
 // New returns a *JEXAdapter
-func New(cfg *viper.Viper, detector *millicores.Detector, messenger Messenger) *JEXAdapter {
-	return &JEXAdapter{
+func New(cfg *viper.Viper, detector *millicores.Detector, messenger Messenger, opts ...Option) *JEXAdapter {
+	j := &JEXAdapter{
 		cfg:       cfg,
 		messenger: messenger,
 		detector:  detector,
@@ -182,6 +379,10 @@ func New(cfg *viper.Viper, detector *millicores.Detector, messenger Messenger) *
 		exit:      make(chan bool),
 		jobs:      map[string]bool{},
 	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
 }
 
 func (j *JEXAdapter) Run() {
@@ -194,6 +395,9 @@ RunLoop:
 				ctx, span := otel.Tracer(otelName).Start(context.Background(), "millicores iteration")
 				defer span.End()
 
+				ctx = logging.WithJob(ctx, mj.Job.InvocationID, mj.Job.Submitter)
+				log := log.WithContext(ctx)
+
 				var err error
 
 				log.Infof("storing %s millicores reserved for %s", mj.MillicoresReserved.String(), mj.Job.InvocationID)
@@ -230,6 +434,9 @@ func (j *JEXAdapter) Finish() {
 	j.exit <- true
 }
 
+// Routes adds the routes that mimic the old JEX API. The launch and stop routes
+// are kept for existing callers, but they're deprecated in favor of the ones
+// added by V1Routes.
 func (j *JEXAdapter) Routes(router types.Router) types.Router {
 	log := log.WithFields(logrus.Fields{"context": "adding routes"})
 
@@ -237,12 +444,35 @@ func (j *JEXAdapter) Routes(router types.Router) types.Router {
 	router.GET("/", j.HomeHandler)
 	log.Info("added handler for GET /")
 
-	router.POST("", j.LaunchHandler)
-	router.POST("/", j.LaunchHandler)
-	log.Info("added handler for POST /")
+	router.POST("", j.LaunchHandler, logging.Deprecated("/", "/v1/jobs"), auth.Require(auth.PermLaunch))
+	router.POST("/", j.LaunchHandler, logging.Deprecated("/", "/v1/jobs"), auth.Require(auth.PermLaunch))
+	log.Info("added deprecated handler for POST /")
+
+	router.DELETE("/stop/:invocation_id", j.StopHandler, logging.Deprecated("/stop", "/v1/jobs"), auth.Require(auth.PermStopAny, auth.PermStopOwn))
+	log.Info("added deprecated handler for DELETE /stop/:invocation_id")
+
+	return router
+}
+
+// V1Routes adds the versioned job routes. The router is expected to be the
+// /v1 group.
+func (j *JEXAdapter) V1Routes(router types.Router) types.Router {
+	log := log.WithFields(logrus.Fields{"context": "adding v1 routes"})
+
+	router.POST("/jobs", j.LaunchHandler, auth.Require(auth.PermLaunch))
+	log.Info("added handler for POST /v1/jobs")
 
-	router.DELETE("/stop/:invocation_id", j.StopHandler)
-	log.Info("added handler for DELETE /stop/:invocation_id")
+	router.DELETE("/jobs/:invocation_id", j.StopHandler, auth.Require(auth.PermStopAny, auth.PermStopOwn))
+	log.Info("added handler for DELETE /v1/jobs/:invocation_id")
+
+	router.POST("/jobs/:invocation_id/relaunch", j.RelaunchHandler, auth.Require(auth.PermLaunch))
+	log.Info("added handler for POST /v1/jobs/:invocation_id/relaunch")
+
+	router.GET("/scheduled-launches", j.ScheduledHandler, auth.Require(auth.PermAdmin, auth.PermLaunch))
+	log.Info("added handler for GET /v1/scheduled-launches")
+
+	router.DELETE("/scheduled-launches/:invocation_id", j.CancelScheduledHandler, auth.Require(auth.PermStopAny, auth.PermStopOwn))
+	log.Info("added handler for DELETE /v1/scheduled-launches/:invocation_id")
 
 	return router
 }
@@ -251,6 +481,9 @@ func (j *JEXAdapter) HomeHandler(c echo.Context) error {
 	return c.String(http.StatusOK, "Welcome to the JEX.\n")
 }
 
+// StopHandler stops the job in the URL. The force query parameter skips the
+// checks that the job exists and hasn't finished, and requires the admin
+// permission.
 func (j *JEXAdapter) StopHandler(c echo.Context) error {
 	var err error
 
@@ -265,10 +498,230 @@ func (j *JEXAdapter) StopHandler(c echo.Context) error {
 		return err
 	}
 
-	log = log.WithFields(logrus.Fields{"external_id": invID})
+	force := false
+	if v := c.QueryParam("force"); v != "" {
+		if force, err = strconv.ParseBool(v); err != nil {
+			return logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, "force must be true or false")
+		}
+	}
+
+	if force {
+		err = j.ForceStop(context, auth.FromContext(c), invID)
+	} else {
+		err = j.Stop(context, auth.FromContext(c), invID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// Stop sends a stop request for the job with the given invocation ID after
+// making sure that the caller is allowed to stop it. A nil identity skips the
+// ownership check. When a database is available, unknown jobs are rejected
+// with a 404 ErrorResponse and jobs that have already finished with a 409.
+// Jobs that the DE database doesn't know about, such as ones launched without
+// going through the apps service, are looked up in the job states. It's
+// shared by the HTTP and NATS interfaces.
+func (j *JEXAdapter) Stop(context context.Context, id *auth.Identity, invID string) error {
+	return j.stopJob(context, id, invID, false)
+}
+
+// ForceStop sends a stop request for the job even if it can't be found or has
+// already finished. It requires the admin permission; a nil identity skips
+// the check.
+func (j *JEXAdapter) ForceStop(context context.Context, id *auth.Identity, invID string) error {
+	if id != nil && !id.Can(auth.PermAdmin) {
+		return logging.NewStatusErrorResponse(
+			http.StatusForbidden,
+			logging.ErrCodeForbidden,
+			fmt.Sprintf("%s is not allowed to force stop requests", id.Subject),
+		)
+	}
+	return j.stopJob(context, id, invID, true)
+}
+
+// stopJob does the work for Stop and ForceStop.
+func (j *JEXAdapter) stopJob(context context.Context, id *auth.Identity, invID string, force bool) (err error) {
+	rec := &audit.Record{Action: audit.ActionStop, InvocationID: invID}
+	rec.SetIdentity(id)
+	defer func() {
+		j.audit(context, rec, err)
+	}()
+
+	context = logging.WithJob(context, invID, "")
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "stop app"})
+
+	// The caller is authorized first so that the 404 for unknown jobs can't
+	// be used to find out which invocation IDs exist.
+	if err = j.checkStopAllowed(context, id, invID, "stop"); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	var status string
+	if !force && j.db != nil {
+		status, err = j.db.JobStatus(context, invID)
+		if errors.Is(err, db.ErrJobNotFound) && j.states != nil {
+			if js, getErr := j.states.Get(context, invID); getErr == nil {
+				status, err = string(js.State), nil
+			}
+		}
+		if errors.Is(err, db.ErrJobNotFound) {
+			err = logging.NewStatusErrorResponse(
+				http.StatusNotFound,
+				logging.ErrCodeNotFound,
+				fmt.Sprintf("job %s was not found", invID),
+			)
+		}
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	if !force {
+		// The DE database uses the same names for the terminal statuses as
+		// the job status updates do.
+		state, finished := jobstatus.State(status), jobstatus.State(status).Terminal()
+		if !finished {
+			state, finished = j.finished(context, invID)
+		}
+		if finished {
+			err = logging.NewStatusErrorResponse(
+				http.StatusConflict,
+				logging.ErrCodeConflict,
+				fmt.Sprintf("job %s has already finished with the state %s", invID, state),
+			)
+			log.Error(err)
+			return err
+		}
+	} else {
+		log.Info("forcing the stop request")
+	}
+
+	return j.stop(context, invID, stopReason)
+}
+
+// Expire stops a job whose deadline has passed, giving the reason in the stop
+// request. The caller is taken from the context for the audit record.
+func (j *JEXAdapter) Expire(context context.Context, invID, reason string) (err error) {
+	rec := &audit.Record{Action: audit.ActionStop, InvocationID: invID}
+	rec.SetIdentity(auth.IdentityFromContext(context))
+	defer func() {
+		j.audit(context, rec, err)
+	}()
+
+	context = logging.WithJob(context, invID, "")
+
+	// Updates can be missed, so this is only a shortcut. The deadline is
+	// removed by Finished when the job's final update is seen.
+	if state, finished := j.finished(context, invID); finished {
+		log.WithContext(context).Infof("not stopping the job since it already finished with the state %s", state)
+		j.Finished(context, invID, state)
+		return nil
+	}
+
+	return j.stop(context, invID, reason)
+}
+
+// Finished releases what was held for a job that finished: its deadline no
+// longer needs to be enforced and its stop queue can be deleted. The
+// millicores recorded in the jobs table are left alone since they're used to
+// compute CPU hours.
+func (j *JEXAdapter) Finished(context context.Context, invID string, state jobstatus.State) {
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "job finished"})
+
+	if j.deadlines != nil {
+		if err := j.deadlines.Remove(context, invID); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if j.queues != nil {
+		if err := j.queues.Remove(context, invID); err != nil {
+			log.Error(err)
+		}
+	}
+
+	log.Debugf("released the resources held for the job, which finished with the state %s", state)
+}
+
+// setDeadline stores the time limit of a job that was just launched. The clock
+// starts when the job is reported to be running, so that time spent in the
+// backend's queue doesn't count, unless status updates aren't tracked, in
+// which case it starts now.
+func (j *JEXAdapter) setDeadline(context context.Context, job *model.Job, submitter string) {
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "app launch"})
+
+	if j.states == nil {
+		deadline, err := j.deadlines.Set(context, job, submitter)
+		switch {
+		case err != nil:
+			log.Error(err)
+		case !deadline.IsZero():
+			log.Infof("the job will be stopped if it's still running at %s", deadline.Format(time.RFC3339))
+		}
+		return
+	}
+
+	limit, err := j.deadlines.Pending(context, job, submitter)
+	if err != nil || limit <= 0 {
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+	log.Infof("the job will be stopped if it's still running %s after it starts", limit)
+
+	// The job's running update may have arrived before the launch finished.
+	if js, getErr := j.states.Get(context, job.InvocationID); getErr == nil && js.State == jobstatus.Running {
+		j.Running(context, job.InvocationID)
+	}
+}
+
+// Running starts the clock on the time limit of a job that's begun running.
+func (j *JEXAdapter) Running(context context.Context, invID string) {
+	if j.deadlines == nil {
+		return
+	}
+
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "job running"})
+
+	deadline, err := j.deadlines.Start(context, invID)
+	switch {
+	case err != nil:
+		log.Error(err)
+	case !deadline.IsZero():
+		log.Infof("the job will be stopped if it's still running at %s", deadline.Format(time.RFC3339))
+	}
+}
+
+// finished returns the state of a job and true if it's known to have finished.
+func (j *JEXAdapter) finished(context context.Context, invID string) (jobstatus.State, bool) {
+	if j.states == nil {
+		return "", false
+	}
+
+	js, err := j.states.Get(context, invID)
+	if err != nil {
+		if !errors.Is(err, jobstatus.ErrNotFound) {
+			log.WithContext(context).Error(err)
+		}
+		return "", false
+	}
+
+	return js.State, js.State.Terminal()
+}
+
+// stop sends the stop request and removes the job's deadline, since there's
+// no longer any need to enforce it.
+func (j *JEXAdapter) stop(context context.Context, invID, reason string) error {
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "stop app"})
 
 	log.Debug("starting sending stop message")
-	err = j.messenger.Stop(context, invID)
+	err := j.messenger.Stop(context, invID, reason)
 	if err != nil {
 		log.Error(err)
 		return err
@@ -277,15 +730,69 @@ func (j *JEXAdapter) StopHandler(c echo.Context) error {
 
 	log.Info("sent stop message")
 
-	return c.NoContent(http.StatusOK)
+	if j.states != nil {
+		if markErr := j.states.StopRequested(context, invID); markErr != nil {
+			log.Error(markErr)
+		}
+	}
+
+	if j.deadlines != nil {
+		if removeErr := j.deadlines.Remove(context, invID); removeErr != nil {
+			log.Error(removeErr)
+		}
+	}
+
+	return nil
 }
 
+// checkStopAllowed makes sure that a caller who may only stop their own jobs
+// submitted the job being stopped, or being looked at for the other actions
+// that require the stop permissions. A nil identity means that the route
+// isn't protected by the auth middleware.
+func (j *JEXAdapter) checkStopAllowed(ctx context.Context, id *auth.Identity, invID, action string) error {
+	if id == nil || id.Can(auth.PermStopAny) {
+		return nil
+	}
+
+	forbidden := logging.NewStatusErrorResponse(
+		http.StatusForbidden,
+		logging.ErrCodeForbidden,
+		fmt.Sprintf("%s is not allowed to %s %s", id.Subject, action, invID),
+	)
+
+	if !id.Can(auth.PermStopOwn) {
+		return forbidden
+	}
+
+	submitter, err := j.jobSubmitter(ctx, invID)
+	if errors.Is(err, db.ErrJobNotFound) {
+		return forbidden
+	}
+	if err != nil {
+		return err
+	}
+
+	if !id.IsUser(submitter) {
+		return forbidden
+	}
+
+	return nil
+}
+
+// LaunchHandler launches the job submission in the request body. If the
+// start_at or delay query parameter puts the start time in the future, the
+// launch is scheduled instead and the response is a 202 with a
+// ScheduledLaunchResponse.
 func (j *JEXAdapter) LaunchHandler(c echo.Context) error {
 	request := c.Request()
-	context := request.Context()
 
 	log := log.WithFields(logrus.Fields{"context": "app launch"})
 
+	startAt, err := startTime(c)
+	if err != nil {
+		return err
+	}
+
 	log.Debug("reading request body")
 	bodyBytes, err := io.ReadAll(request.Body)
 	if err != nil {
@@ -294,39 +801,220 @@ func (j *JEXAdapter) LaunchHandler(c echo.Context) error {
 	}
 	log.Debug("done reading request body")
 
-	log.Debug("parsing request body JSON")
-	job, err := model.NewFromData(j.cfg, bodyBytes)
-	if err != nil {
-		log.Error(err)
+	if startAt.After(time.Now()) {
+		job, err := j.Schedule(request.Context(), bodyBytes, startAt)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusAccepted, ScheduledLaunchResponse{
+			InvocationID: job.InvocationID,
+			StartAt:      startAt,
+		})
+	}
+
+	if _, err = j.Launch(request.Context(), bodyBytes); err != nil {
 		return err
 	}
-	log.Debug("done parsing request body JSON")
 
-	log = log.WithFields(logrus.Fields{"external_id": job.InvocationID})
+	return c.NoContent(http.StatusOK)
+}
+
+// Parse parses a job submission without launching it. Parse errors are
+// returned as 400 ErrorResponses.
+func (j *JEXAdapter) Parse(body []byte) (*model.Job, error) {
+	job, err := model.NewFromData(j.cfg, body)
+	if err != nil {
+		return nil, logging.ErrorResponse{
+			Message:   fmt.Sprintf("unable to parse the job submission: %s", err),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+	return job, nil
+}
+
+// username returns the username of the job's submitter as it appears in the
+// submission, domain suffix and all. Parsing the submission replaces the '@'
+// in job.Submitter, which would keep it from matching callers and users in
+// the DE database, so job.Submitter is only used if there isn't one.
+func username(body []byte, job *model.Job) string {
+	var submission struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &submission); err != nil || submission.Username == "" {
+		return job.Submitter
+	}
+	return submission.Username
+}
+
+// Status returns the status of the job with the given invocation ID. The state
+// tracked from job status updates is used when there is one; otherwise it's
+// the status recorded in the DE database. Callers who may only stop their own
+// jobs may only look up the status of their own jobs; a nil identity skips the
+// check.
+func (j *JEXAdapter) Status(context context.Context, id *auth.Identity, invID string) (string, error) {
+	if err := j.checkStopAllowed(context, id, invID, "look up the status of"); err != nil {
+		return "", err
+	}
+
+	if j.states != nil {
+		js, err := j.states.Get(context, invID)
+		if err == nil {
+			return string(js.State), nil
+		}
+		if !errors.Is(err, jobstatus.ErrNotFound) {
+			log.WithContext(context).Error(err)
+		}
+	}
+
+	if j.db == nil {
+		return "", errors.New("job status lookups require a database")
+	}
+
+	status, err := j.db.JobStatus(context, invID)
+	if errors.Is(err, db.ErrJobNotFound) {
+		return "", logging.NewStatusErrorResponse(
+			http.StatusNotFound,
+			logging.ErrCodeNotFound,
+			fmt.Sprintf("job %s was not found", invID),
+		)
+	}
+	return status, err
+}
+
+// Launch parses a job submission, publishes the launch request, and records
+// the number of millicores reserved for the job. It's shared by the HTTP and
+// NATS interfaces.
+func (j *JEXAdapter) Launch(context context.Context, body []byte) (*model.Job, error) {
+	return j.launch(context, body, nil, "")
+}
+
+// launch does the work for Launch and Relaunch. The body is parsed unless the
+// job has already been parsed from it. The relaunchedFrom argument is the
+// invocation ID of the job being relaunched, if any.
+func (j *JEXAdapter) launch(context context.Context, body []byte, job *model.Job, relaunchedFrom string) (_ *model.Job, err error) {
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "app launch"})
+
+	rec := &audit.Record{Action: audit.ActionLaunch}
+	if relaunchedFrom != "" {
+		rec.Action = audit.ActionRelaunch
+	}
+	rec.SetIdentity(auth.IdentityFromContext(context))
+	defer func() {
+		j.audit(context, rec, err)
+	}()
+
+	if job == nil {
+		log.Debug("parsing request body JSON")
+		job, err = j.Parse(body)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		log.Debug("done parsing request body JSON")
+	}
+
+	submitter := username(body, job)
+	rec.InvocationID = job.InvocationID
+	rec.Submitter = submitter
+	rec.AppID = job.AppID
+
+	context = logging.WithJob(context, job.InvocationID, submitter)
+	log = log.WithContext(context)
 
 	log.Debug("sending launch message")
 	if err = j.messenger.Launch(context, job); err != nil {
 		log.Error(err)
-		return err
+		return nil, err
 	}
 	log.Debug("done sending launch message")
 
+	if j.subs != nil {
+		log.Debug("storing the submission")
+		if saveErr := j.subs.Save(context, body, job, submitter, relaunchedFrom); saveErr != nil {
+			log.Error(saveErr)
+		}
+	}
+
+	if j.states != nil {
+		if trackErr := j.states.Submitted(context, job, submitter); trackErr != nil {
+			log.Error(trackErr)
+		}
+	}
+
+	if j.queues != nil {
+		if queueErr := j.queues.Created(context, job.InvocationID); queueErr != nil {
+			log.Error(queueErr)
+		}
+	}
+
+	if j.deadlines != nil {
+		j.setDeadline(context, job, submitter)
+	}
+
 	log.Debug("finding number of millicores reserved")
 	millicoresReserved, err := j.detector.NumberReserved(job)
 	if err != nil {
 		log.Error(err)
-		return err
+		return nil, err
 	}
 	log.Debug("done finding number of millicores reserved")
 
+	if reserved, convErr := millicoresReserved.Float64(); convErr == nil {
+		rec.MillicoresReserved = int64(reserved)
+	}
+
 	log.Debug("before asynchronous StoreMillicoresReserved call")
 	if err = j.StoreMillicoresReserved(*job, millicoresReserved); err != nil {
 		log.Error(err)
-		return err
+		return nil, err
 	}
 	log.Debug("after asynchronous StoreMillicoresReserved call")
 
 	log.Infof("launched with %f millicores reserved", millicoresReserved)
 
-	return c.NoContent(http.StatusOK)
+	return job, nil
+}
+
+// jobSubmitter returns the username of the job's submitter from the DE
+// database, or from the job states if the database doesn't know the job. It
+// returns db.ErrJobNotFound if neither does.
+func (j *JEXAdapter) jobSubmitter(ctx context.Context, invID string) (string, error) {
+	if j.db != nil {
+		submitter, err := j.db.JobSubmitter(ctx, invID)
+		if !errors.Is(err, db.ErrJobNotFound) {
+			return submitter, err
+		}
+	}
+
+	if j.states != nil {
+		js, err := j.states.Get(ctx, invID)
+		if errors.Is(err, jobstatus.ErrNotFound) {
+			return "", db.ErrJobNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return js.Submitter, nil
+	}
+
+	return "", db.ErrJobNotFound
+}
+
+// audit records the outcome of an audited request if an auditor is
+// configured. The submitter of a stopped job is looked up in the DE database,
+// or in the job states if the database doesn't know the job, so that it's
+// recorded the same way as for launches: as the username from the submission.
+func (j *JEXAdapter) audit(ctx context.Context, rec *audit.Record, err error) {
+	if j.auditor == nil {
+		return
+	}
+
+	if rec.Submitter == "" && rec.InvocationID != "" {
+		if submitter, lookupErr := j.jobSubmitter(ctx, rec.InvocationID); lookupErr == nil {
+			rec.Submitter = submitter
+		}
+	}
+
+	rec.SetResult(err)
+	j.auditor.Record(ctx, rec)
 }
@@ -1,20 +1,40 @@
 package adapter
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cyverse-de/jex-adapter/audit"
+	"github.com/cyverse-de/jex-adapter/auth"
 	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/deadlines"
+	"github.com/cyverse-de/jex-adapter/jobstatus"
+	"github.com/cyverse-de/jex-adapter/logging"
 	"github.com/cyverse-de/jex-adapter/millicores"
+	"github.com/cyverse-de/jex-adapter/scheduler"
+	"github.com/cyverse-de/jex-adapter/stopqueues"
+	"github.com/cyverse-de/jex-adapter/submissions"
 	"github.com/cyverse-de/model/v6"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -29,7 +49,7 @@ func getTestConfig() *viper.Viper {
 
 type TestMessenger struct{}
 
-func (t *TestMessenger) Stop(context context.Context, id string) error {
+func (t *TestMessenger) Stop(context context.Context, id, reason string) error {
 	return nil
 }
 
@@ -126,3 +146,1051 @@ func TestCondorLaunchDefaultMillicores(t *testing.T) {
 		assert.Equal(t, rec.Code, http.StatusOK)
 	}
 }
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	a, _ := initTestAdapter(t)
+	go a.Run()
+	defer a.Finish()
+
+	e := echo.New()
+	e.Use(auth.New(false, nil).Middleware())
+	a.Routes(e)
+	a.V1Routes(e.Group("/v1"))
+
+	req := httptest.NewRequest(http.MethodDelete, "/stop/c654e8bb-d535-4f7a-bd0f-aff0f0c189b1", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/jobs/c654e8bb-d535-4f7a-bd0f-aff0f0c189b1>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/jobs/c654e8bb-d535-4f7a-bd0f-aff0f0c189b1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+}
+
+type auditWriter struct {
+	records []audit.Record
+}
+
+func (w *auditWriter) Write(_ context.Context, rec *audit.Record) error {
+	w.records = append(w.records, *rec)
+	return nil
+}
+
+func TestLaunchAndStopAreAudited(t *testing.T) {
+	base, _ := initTestAdapter(t)
+	w := &auditWriter{}
+	a := New(base.cfg, base.detector, base.messenger, WithAuditor(audit.New(nil, w)))
+	go a.Run()
+	defer a.Finish()
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", strings.NewReader(testCondorLaunchJSON))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs", strings.NewReader(`{"steps": "nope"}`))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/jobs/c654e8bb-d535-4f7a-bd0f-aff0f0c189b1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	if assert.Len(t, w.records, 3) {
+		launched := w.records[0]
+		assert.Equal(t, audit.ActionLaunch, launched.Action)
+		assert.Equal(t, audit.OutcomeSuccess, launched.Outcome)
+		assert.Equal(t, "anonymous", launched.Caller)
+		assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", launched.InvocationID)
+		assert.Equal(t, "test@this is a test", launched.Submitter)
+		assert.Positive(t, launched.MillicoresReserved)
+
+		rejected := w.records[1]
+		assert.Equal(t, audit.OutcomeRejected, rejected.Outcome)
+		assert.Equal(t, logging.ErrCodeBadRequest, rejected.ErrorCode)
+
+		stopped := w.records[2]
+		assert.Equal(t, audit.ActionStop, stopped.Action)
+		assert.Equal(t, audit.OutcomeSuccess, stopped.Outcome)
+		assert.Equal(t, "c654e8bb-d535-4f7a-bd0f-aff0f0c189b1", stopped.InvocationID)
+	}
+}
+
+func TestLaunchMatchesSubmitterDebugTarget(t *testing.T) {
+	var buf bytes.Buffer
+	out, formatter := logging.Log.Logger.Out, logging.Log.Logger.Formatter
+	logging.SetupLogging("info", "json")
+	logging.Log.Logger.SetOutput(&buf)
+	defer func() {
+		logging.Log.Logger.SetOutput(out)
+		logging.Log.Logger.SetFormatter(formatter)
+		logging.Log.Logger.ReplaceHooks(logrus.LevelHooks{})
+		logging.DisableDebug(logging.SubmitterField, "ipcdev")
+		logging.SetLevel(logrus.InfoLevel)
+	}()
+
+	base, _ := initTestAdapter(t)
+	a := New(base.cfg, base.detector, &recordingMessenger{})
+	go a.Run()
+	defer a.Finish()
+
+	logging.EnableDebug(logging.SubmitterField, "ipcdev@iplantcollaborative.org", time.Minute)
+
+	body := strings.Replace(testCondorLaunchJSON, "test@this is a test", "ipcdev@iplantcollaborative.org", 1)
+	_, err := a.Launch(context.Background(), []byte(body))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "sending launch message")
+}
+
+func TestLaunchStoresSubmission(t *testing.T) {
+	base, _ := initTestAdapter(t)
+
+	conn, subsMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	store := submissions.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, base.messenger, WithSubmissions(store))
+	go a.Run()
+	defer a.Finish()
+
+	subsMock.ExpectExec("INSERT INTO "+submissions.Table).
+		WithArgs("07b04ce2-7757-4b21-9e15-0b4c2f44be26", "test@this is a test", sqlmock.AnyArg(), "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = a.Launch(context.Background(), []byte(testCondorLaunchJSON))
+	assert.NoError(t, err)
+	assert.NoError(t, subsMock.ExpectationsWereMet())
+}
+
+type recordingMessenger struct {
+	launched []*model.Job
+	stopped  []string
+	reasons  []string
+}
+
+func (r *recordingMessenger) Launch(context context.Context, job *model.Job) error {
+	r.launched = append(r.launched, job)
+	return nil
+}
+
+func (r *recordingMessenger) Stop(context context.Context, id, reason string) error {
+	r.stopped = append(r.stopped, id)
+	r.reasons = append(r.reasons, reason)
+	return nil
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// containing matches a string argument that contains the substring.
+type containing string
+
+func (c containing) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.Contains(s, string(c))
+}
+
+func TestRelaunch(t *testing.T) {
+	const original = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, subsMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	deConn, deMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer deConn.Close()
+
+	dbase := db.New(sqlx.NewDb(deConn, "postgres"))
+	detector, err := millicores.New(dbase, 4000.0)
+	require.NoError(t, err)
+
+	msger := &recordingMessenger{}
+	store := submissions.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, detector, msger, WithSubmissions(store), WithDatabase(dbase))
+	go a.Run()
+	defer a.Finish()
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	columns := []string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}
+	subsMock.ExpectQuery("SELECT (.+) FROM "+submissions.Table).
+		WithArgs(original, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(original, time.Now(), "test", "app", "", gzipBytes(t, []byte(testCondorLaunchJSON)), gzipBytes(t, []byte("{}"))))
+	subsMock.ExpectExec("INSERT INTO "+submissions.Table).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), original, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The relaunched job is added to the DE database with the overridden
+	// submission before it's launched, so the millicores reserved for it can
+	// be recorded. The job ID is looked up as many times as for any other job.
+	deMock.ExpectExec("INSERT INTO jobs").
+		WithArgs(original, sqlmock.AnyArg(), "Word Count analysis1@@", containing("/iplant/home/ipcdev/analyses/rerun/"), containing(`"output_dir":"/iplant/home/ipcdev/analyses/rerun"`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for i := 0; i < 30; i++ {
+		deMock.ExpectQuery("SELECT job_id FROM job_steps").
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("relaunched-job"))
+	}
+	deMock.ExpectExec("UPDATE jobs").
+		WithArgs("relaunched-job", int64(4000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"output_dir": "/iplant/home/ipcdev/analyses/rerun", "max_cpu_cores": 4, "memory_limit": 8589934592}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/"+original+"/relaunch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp RelaunchResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, original, resp.RelaunchedFrom)
+	assert.NotEqual(t, original, resp.InvocationID)
+
+	if assert.Len(t, msger.launched, 1) {
+		job := msger.launched[0]
+		assert.Equal(t, resp.InvocationID, job.InvocationID)
+		assert.Equal(t, "/iplant/home/ipcdev/analyses/rerun", job.OutputDir)
+		for _, step := range job.Steps {
+			assert.Equal(t, float32(4), step.Component.Container.MaxCPUCores)
+			assert.Equal(t, int64(8589934592), step.Component.Container.MemoryLimit)
+		}
+	}
+	assert.NoError(t, subsMock.ExpectationsWereMet())
+	assert.Eventually(t, func() bool { return deMock.ExpectationsWereMet() == nil }, 5*time.Second, 10*time.Millisecond)
+
+	subsMock.ExpectQuery("SELECT (.+) FROM " + submissions.Table).WillReturnRows(sqlmock.NewRows(columns))
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs/c654e8bb-d535-4f7a-bd0f-aff0f0c189b1/relaunch", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Jobs that the DE database doesn't know can't be relaunched.
+	subsMock.ExpectQuery("SELECT (.+) FROM "+submissions.Table).
+		WithArgs(original, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(original, time.Now(), "test", "app", "", gzipBytes(t, []byte(testCondorLaunchJSON)), gzipBytes(t, []byte("{}"))))
+	deMock.ExpectExec("INSERT INTO jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs/"+original+"/relaunch", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Len(t, msger.launched, 1)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs/"+original+"/relaunch", strings.NewReader(`{"min_cpu_cores": 8, "max_cpu_cores": 2}`))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRelaunchOwnership(t *testing.T) {
+	const original = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, subsMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	deConn, deMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer deConn.Close()
+
+	msger := &recordingMessenger{}
+	store := submissions.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, msger, WithSubmissions(store), WithDatabase(db.New(sqlx.NewDb(deConn, "postgres"))))
+
+	userAuth := auth.New(true, map[string][]auth.Permission{
+		"user":     {auth.PermLaunch, auth.PermStopOwn},
+		"operator": {auth.PermLaunch, auth.PermStopAny},
+		"support":  {auth.PermLaunch, auth.PermRelaunchAny},
+		"admin":    {auth.PermLaunch, auth.PermAdmin},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "ipcdev", Key: "ipcdev-secret", Roles: []string{"user"}},
+		{Name: "wregglej", Key: "wregglej-secret", Roles: []string{"user"}},
+		{Name: "operator", Key: "operator-secret", Roles: []string{"operator"}},
+		{Name: "support", Key: "support-secret", Roles: []string{"support"}},
+		{Name: "ops", Key: "admin-secret", Roles: []string{"admin"}},
+	}))
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(userAuth.Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	columns := []string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}
+
+	// The DE database doesn't know the job, so callers who get past the
+	// ownership check see a 404 and nothing is launched.
+	for _, tt := range []struct {
+		key    string
+		stored bool
+		want   int
+	}{
+		{"ipcdev-secret", true, http.StatusNotFound},
+		{"support-secret", true, http.StatusNotFound},
+		{"admin-secret", true, http.StatusNotFound},
+		{"operator-secret", true, http.StatusForbidden},
+		{"wregglej-secret", true, http.StatusForbidden},
+		{"wregglej-secret", false, http.StatusForbidden},
+	} {
+		rows := sqlmock.NewRows(columns)
+		if tt.stored {
+			rows.AddRow(original, time.Now(), "ipcdev@iplantcollaborative.org", "app", "", gzipBytes(t, []byte(testCondorLaunchJSON)), gzipBytes(t, []byte("{}")))
+		}
+		subsMock.ExpectQuery("SELECT (.+) FROM " + submissions.Table).WillReturnRows(rows)
+		if tt.want == http.StatusNotFound {
+			deMock.ExpectExec("INSERT INTO jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/jobs/"+original+"/relaunch", nil)
+		req.Header.Set("X-API-Key", tt.key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tt.want, rec.Code, tt.key)
+	}
+
+	assert.Empty(t, msger.launched)
+	assert.NoError(t, subsMock.ExpectationsWereMet())
+	assert.NoError(t, deMock.ExpectationsWereMet())
+}
+
+func TestScheduledLaunch(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, schedMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	msger := &recordingMessenger{}
+	w := &auditWriter{}
+	store := scheduler.New(sqlx.NewDb(conn, "postgres"), 24*time.Hour)
+	a := New(base.cfg, base.detector, msger, WithScheduler(store), WithAuditor(audit.New(nil, w)))
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	// The submitter is saved as it appears in the submission.
+	schedMock.ExpectExec("INSERT INTO "+scheduler.Table).
+		WithArgs(invID, sqlmock.AnyArg(), "anonymous", "test@this is a test", sqlmock.AnyArg(), []byte(testCondorLaunchJSON)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs?delay=2h", strings.NewReader(testCondorLaunchJSON))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var resp ScheduledLaunchResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, invID, resp.InvocationID)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), resp.StartAt, time.Minute)
+	assert.Empty(t, msger.launched)
+	assert.NoError(t, schedMock.ExpectationsWereMet())
+
+	for _, query := range []string{"delay=48h", "delay=soon", "start_at=tomorrow", "delay=1h&start_at=2026-10-19T12:00:00Z"} {
+		req = httptest.NewRequest(http.MethodPost, "/v1/jobs?"+query, strings.NewReader(testCondorLaunchJSON))
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	schedMock.ExpectQuery("SELECT (.+) FROM " + scheduler.Table).
+		WithArgs(invID).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "start_at", "caller", "submitter", "app_id", "status", "launched_at", "error"}).
+			AddRow(invID, time.Now(), time.Now().Add(time.Hour), "anonymous", "ipcdev", "app", scheduler.StatusPending, nil, ""))
+	schedMock.ExpectExec("UPDATE " + scheduler.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/scheduled-launches/"+invID, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, schedMock.ExpectationsWereMet())
+
+	// Callers who may stop their own jobs can cancel launches submitted
+	// under their username with a domain suffix, but not anyone else's.
+	userAuth := auth.New(true, map[string][]auth.Permission{"user": {auth.PermStopOwn}},
+		auth.NewAPIKeyAuthenticator([]auth.APIKey{{Name: "ipcdev", Key: "secret", Roles: []string{"user"}}}))
+	e = echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(userAuth.Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	for _, tt := range []struct {
+		submitter string
+		want      int
+	}{
+		{"ipcdev@iplantcollaborative.org", http.StatusOK},
+		{"wregglej@iplantcollaborative.org", http.StatusForbidden},
+	} {
+		schedMock.ExpectQuery("SELECT (.+) FROM " + scheduler.Table).
+			WithArgs(invID).
+			WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "start_at", "caller", "submitter", "app_id", "status", "launched_at", "error"}).
+				AddRow(invID, time.Now(), time.Now().Add(time.Hour), "apps", tt.submitter, "app", scheduler.StatusPending, nil, ""))
+		if tt.want == http.StatusOK {
+			schedMock.ExpectExec("UPDATE " + scheduler.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		req = httptest.NewRequest(http.MethodDelete, "/v1/scheduled-launches/"+invID, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tt.want, rec.Code, tt.submitter)
+		assert.NoError(t, schedMock.ExpectationsWereMet())
+	}
+
+	// Scheduling and canceling are audited like launches and stops. The
+	// launch scheduled too far ahead is the only other audited request.
+	if assert.Len(t, w.records, 5) {
+		scheduled := w.records[0]
+		assert.Equal(t, audit.ActionSchedule, scheduled.Action)
+		assert.Equal(t, audit.OutcomeSuccess, scheduled.Outcome)
+		assert.Equal(t, "anonymous", scheduled.Caller)
+		assert.Equal(t, invID, scheduled.InvocationID)
+		assert.Equal(t, "test@this is a test", scheduled.Submitter)
+
+		assert.Equal(t, audit.ActionSchedule, w.records[1].Action)
+		assert.Equal(t, audit.OutcomeRejected, w.records[1].Outcome)
+
+		for i, want := range []struct {
+			caller, submitter, outcome string
+		}{
+			{"anonymous", "ipcdev", audit.OutcomeSuccess},
+			{"ipcdev", "ipcdev@iplantcollaborative.org", audit.OutcomeSuccess},
+			{"ipcdev", "wregglej@iplantcollaborative.org", audit.OutcomeRejected},
+		} {
+			canceled := w.records[i+2]
+			assert.Equal(t, audit.ActionCancel, canceled.Action)
+			assert.Equal(t, want.caller, canceled.Caller)
+			assert.Equal(t, want.submitter, canceled.Submitter)
+			assert.Equal(t, want.outcome, canceled.Outcome)
+			assert.Equal(t, invID, canceled.InvocationID)
+		}
+	}
+}
+
+func TestDeadlines(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, deadlineMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	msger := &recordingMessenger{}
+	store := deadlines.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, msger, WithDeadlines(store))
+	go a.Run()
+	defer a.Finish()
+
+	// Without state tracking, the clock starts at launch.
+	deadlineMock.ExpectExec("INSERT INTO "+deadlines.Table).
+		WithArgs(invID, sqlmock.AnyArg(), int64(3600), "test@this is a test").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = a.Launch(context.Background(), []byte(testCondorLaunchJSON))
+	assert.NoError(t, err)
+	assert.NoError(t, deadlineMock.ExpectationsWereMet())
+
+	deadlineMock.ExpectExec("DELETE FROM " + deadlines.Table).
+		WithArgs(invID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, a.Expire(context.Background(), invID, deadlines.Reason))
+	assert.Equal(t, []string{invID}, msger.stopped)
+	assert.Equal(t, []string{deadlines.Reason}, msger.reasons)
+	assert.NoError(t, deadlineMock.ExpectationsWereMet())
+
+	// Stopping a job on request also removes its deadline.
+	deadlineMock.ExpectExec("DELETE FROM " + deadlines.Table).
+		WithArgs(invID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, a.Stop(context.Background(), nil, invID))
+	assert.Equal(t, stopReason, msger.reasons[1])
+	assert.NoError(t, deadlineMock.ExpectationsWereMet())
+}
+
+func TestDeadlinesStartWhenRunning(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	dbconn := sqlx.NewDb(conn, "postgres")
+	a := New(base.cfg, base.detector, &recordingMessenger{},
+		WithDeadlines(deadlines.New(dbconn, time.Hour)),
+		WithJobStatus(jobstatus.New(dbconn, time.Hour)),
+	)
+	go a.Run()
+	defer a.Finish()
+
+	// The time limit is stored without a deadline while the job waits in
+	// the backend's queue.
+	dbMock.ExpectExec("INSERT INTO " + jobstatus.Table).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("INSERT INTO "+deadlines.Table).
+		WithArgs(invID, int64(3600), "test@this is a test").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"}).
+			AddRow(invID, jobstatus.Submitted, "test@this is a test", "", false, time.Now(), time.Now()))
+
+	_, err = a.Launch(context.Background(), []byte(testCondorLaunchJSON))
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+
+	// The clock starts when the job is reported to be running.
+	dbMock.ExpectQuery("UPDATE " + deadlines.Table).WithArgs(invID).
+		WillReturnRows(sqlmock.NewRows([]string{"deadline"}).AddRow(time.Now().Add(time.Hour)))
+
+	a.Running(context.Background(), invID)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeadlinesEnforced(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, deadlineMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	msger := &recordingMessenger{}
+	store := deadlines.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, msger, WithDeadlines(store))
+
+	// The deadline is claimed without holding a transaction open, so the
+	// adapter can remove it while the job is being stopped.
+	deadlineMock.ExpectQuery("FOR UPDATE SKIP LOCKED").
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "deadline", "submitter", "attempts"}).
+			AddRow(invID, time.Now(), "ipcdev", 1))
+	deadlineMock.ExpectExec("DELETE FROM " + deadlines.Table).
+		WithArgs(invID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	deadlineMock.ExpectExec("DELETE FROM " + deadlines.Table).
+		WithArgs(invID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := store.StopExpired(context.Background(), a, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{invID}, msger.stopped)
+	assert.Equal(t, []string{deadlines.Reason}, msger.reasons)
+	assert.NoError(t, deadlineMock.ExpectationsWereMet())
+}
+
+func TestJobStatus(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, statesMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	msger := &recordingMessenger{}
+	tracker := jobstatus.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, msger, WithJobStatus(tracker))
+
+	columns := []string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"}
+	stateRows := func(state jobstatus.State) *sqlmock.Rows {
+		return sqlmock.NewRows(columns).AddRow(invID, state, "ipcdev", "", false, time.Now(), time.Now())
+	}
+
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows(jobstatus.Running))
+	status, err := a.Status(context.Background(), nil, invID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Running", status)
+
+	// Callers who may only stop their own jobs can't look up anyone else's.
+	userAuth := auth.New(true, map[string][]auth.Permission{"user": {auth.PermStopOwn}},
+		auth.NewAPIKeyAuthenticator([]auth.APIKey{{Name: "wregglej", Key: "secret", Roles: []string{"user"}}}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "secret")
+	id, err := userAuth.Identify(req)
+	require.NoError(t, err)
+
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows(jobstatus.Running))
+	_, err = a.Status(context.Background(), id, invID)
+	var errResp logging.ErrorResponse
+	if assert.ErrorAs(t, err, &errResp) {
+		assert.Equal(t, http.StatusForbidden, errResp.StatusCode())
+	}
+
+	// Running jobs can be stopped, and the stop request is noted.
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows(jobstatus.Running))
+	statesMock.ExpectExec("UPDATE " + jobstatus.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, a.Stop(context.Background(), nil, invID))
+	assert.Equal(t, []string{invID}, msger.stopped)
+
+	// Finished jobs can't.
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows(jobstatus.Completed))
+	err = a.Stop(context.Background(), nil, invID)
+	if assert.ErrorAs(t, err, &errResp) {
+		assert.Equal(t, http.StatusConflict, errResp.StatusCode())
+		assert.Equal(t, logging.ErrCodeConflict, errResp.ErrorCode)
+	}
+	assert.Len(t, msger.stopped, 1)
+
+	assert.NoError(t, statesMock.ExpectationsWereMet())
+}
+
+func TestAuditedSubmitterIsUsername(t *testing.T) {
+	const (
+		invID    = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+		username = "ipcdev@iplantcollaborative.org"
+	)
+
+	base, _ := initTestAdapter(t)
+
+	conn, statesMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	w := &auditWriter{}
+	tracker := jobstatus.New(sqlx.NewDb(conn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, &recordingMessenger{}, WithJobStatus(tracker), WithAuditor(audit.New(nil, w)))
+	go a.Run()
+	defer a.Finish()
+
+	// Launches and stops both record the username from the submission, not
+	// the sanitized job.Submitter.
+	statesMock.ExpectExec("INSERT INTO "+jobstatus.Table).
+		WithArgs(invID, jobstatus.Submitted, username).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = a.Launch(context.Background(), []byte(strings.Replace(testCondorLaunchJSON, "test@this is a test", username, 1)))
+	require.NoError(t, err)
+
+	stateRows := sqlmock.NewRows([]string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"})
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).
+		WillReturnRows(stateRows.AddRow(invID, jobstatus.Running, username, "", false, time.Now(), time.Now()))
+	statesMock.ExpectExec("UPDATE " + jobstatus.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"}).
+			AddRow(invID, jobstatus.Running, username, "", true, time.Now(), time.Now()))
+	require.NoError(t, a.Stop(context.Background(), nil, invID))
+
+	if assert.Len(t, w.records, 2) {
+		assert.Equal(t, username, w.records[0].Submitter)
+		assert.Equal(t, username, w.records[1].Submitter)
+	}
+	assert.NoError(t, statesMock.ExpectationsWereMet())
+}
+
+type queueDeleter struct {
+	deleted []string
+}
+
+func (q *queueDeleter) DeleteStopQueue(context context.Context, invID string) error {
+	q.deleted = append(q.deleted, invID)
+	return nil
+}
+
+func TestFinishedReleasesJob(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+	dbconn := sqlx.NewDb(conn, "postgres")
+
+	deleter := &queueDeleter{}
+	a := New(base.cfg, base.detector, &recordingMessenger{},
+		WithDeadlines(deadlines.New(dbconn, time.Hour)),
+		WithStopQueues(stopqueues.New(dbconn, deleter, nil, time.Hour)),
+	)
+
+	dbMock.ExpectExec("DELETE FROM " + deadlines.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM " + stopqueues.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a.Finished(context.Background(), invID, jobstatus.Completed)
+	assert.Equal(t, []string{invID}, deleter.deleted)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestStopChecksJob(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	conn, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer conn.Close()
+
+	msger := &recordingMessenger{}
+	a := New(base.cfg, base.detector, msger, WithDatabase(db.New(sqlx.NewDb(conn, "postgres"))))
+
+	authn := auth.New(true, map[string][]auth.Permission{
+		"admin":    {auth.PermAdmin, auth.PermStopAny},
+		"operator": {auth.PermLaunch, auth.PermStopAny},
+		"user":     {auth.PermStopOwn},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "ops", Key: "admin-key", Roles: []string{"admin"}},
+		{Name: "apps", Key: "operator-key", Roles: []string{"operator"}},
+		{Name: "ipcdev", Key: "user-key", Roles: []string{"user"}},
+	}))
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(authn.Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	stop := func(target, key string) int {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	statusQuery := "SELECT j.status FROM job_steps"
+	statusRows := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"status"}).AddRow(status)
+	}
+
+	dbMock.ExpectQuery(statusQuery).WithArgs("typo").WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	assert.Equal(t, http.StatusNotFound, stop("/v1/jobs/typo", "operator-key"))
+
+	// Callers who may only stop their own jobs are authorized before the
+	// lookup, so they can't tell unknown jobs from other users' jobs.
+	submitterQuery := "SELECT u.username"
+	dbMock.ExpectQuery(submitterQuery).WithArgs("typo").WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	assert.Equal(t, http.StatusForbidden, stop("/v1/jobs/typo", "user-key"))
+	dbMock.ExpectQuery(submitterQuery).WithArgs(invID).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("wregglej@iplantcollaborative.org"))
+	assert.Equal(t, http.StatusForbidden, stop("/v1/jobs/"+invID, "user-key"))
+
+	dbMock.ExpectQuery(statusQuery).WithArgs(invID).WillReturnRows(statusRows("Completed"))
+	assert.Equal(t, http.StatusConflict, stop("/v1/jobs/"+invID, "operator-key"))
+	assert.Empty(t, msger.stopped)
+
+	dbMock.ExpectQuery(statusQuery).WithArgs(invID).WillReturnRows(statusRows("Running"))
+	assert.Equal(t, http.StatusOK, stop("/v1/jobs/"+invID, "operator-key"))
+	assert.Equal(t, []string{invID}, msger.stopped)
+
+	// Only admins can force a stop, which skips the lookup.
+	assert.Equal(t, http.StatusForbidden, stop("/v1/jobs/typo?force=true", "operator-key"))
+	assert.Equal(t, http.StatusBadRequest, stop("/v1/jobs/typo?force=maybe", "admin-key"))
+	assert.Equal(t, http.StatusOK, stop("/v1/jobs/typo?force=true", "admin-key"))
+	assert.Equal(t, []string{invID, "typo"}, msger.stopped)
+
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestStopRelaunchedJob(t *testing.T) {
+	const invID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+	base, _ := initTestAdapter(t)
+
+	dbConn, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer dbConn.Close()
+
+	statesConn, statesMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mocked database connection: %s", err)
+	}
+	defer statesConn.Close()
+
+	msger := &recordingMessenger{}
+	tracker := jobstatus.New(sqlx.NewDb(statesConn, "postgres"), time.Hour)
+	a := New(base.cfg, base.detector, msger, WithDatabase(db.New(sqlx.NewDb(dbConn, "postgres"))), WithJobStatus(tracker))
+
+	authn := auth.New(true, map[string][]auth.Permission{
+		"user": {auth.PermStopOwn},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "ipcdev", Key: "user-key", Roles: []string{"user"}},
+	}))
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(authn.Middleware())
+	a.V1Routes(e.Group("/v1"))
+
+	stop := func(target string) int {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req.Header.Set("X-API-Key", "user-key")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	noRows := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"username"}) }
+	stateRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"}).
+			AddRow(invID, jobstatus.Running, "ipcdev@iplantcollaborative.org", "", false, time.Now(), time.Now())
+	}
+
+	// Relaunched jobs aren't in the DE database, so their submitter and state
+	// come from the job states.
+	dbMock.ExpectQuery("SELECT u.username").WithArgs(invID).WillReturnRows(noRows())
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows())
+	dbMock.ExpectQuery("SELECT j.status FROM job_steps").WithArgs(invID).WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows())
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs(invID).WillReturnRows(stateRows())
+	statesMock.ExpectExec("UPDATE " + jobstatus.Table).WithArgs(invID).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, http.StatusOK, stop("/v1/jobs/"+invID))
+	assert.Equal(t, []string{invID}, msger.stopped)
+
+	// Jobs that neither knows about can't be stopped.
+	dbMock.ExpectQuery("SELECT u.username").WithArgs("typo").WillReturnRows(noRows())
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs("typo").
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id"}))
+	assert.Equal(t, http.StatusForbidden, stop("/v1/jobs/typo"))
+
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	assert.NoError(t, statesMock.ExpectationsWereMet())
+}
+
+// fakeChannel answers each publish the way the broker would, with an optional
+// return followed by a confirmation.
+type fakeChannel struct {
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	tag      uint64
+	nack     bool
+	unroute  bool
+	silent   bool
+	late     []amqp.Return
+	keys     []string
+	ids      []string
+	headers  []amqp.Table
+}
+
+// open returns the fake as a channel in confirm mode.
+func (f *fakeChannel) open() (*confirmChannel, error) {
+	return &confirmChannel{publishChannel: f, confirms: f.confirms, returns: f.returns, closed: make(chan *amqp.Error, 1)}, nil
+}
+
+func (f *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	f.keys = append(f.keys, key)
+	f.ids = append(f.ids, msg.MessageId)
+	f.headers = append(f.headers, msg.Headers)
+	f.tag++
+	for _, ret := range f.late {
+		f.returns <- ret
+	}
+	f.late = nil
+	if f.silent {
+		return nil
+	}
+	if f.unroute && mandatory {
+		f.returns <- amqp.Return{MessageId: msg.MessageId, RoutingKey: key, ReplyText: "NO_ROUTE"}
+	}
+	f.confirms <- amqp.Confirmation{DeliveryTag: f.tag, Ack: !f.nack}
+	return nil
+}
+
+func TestConfirmPublisher(t *testing.T) {
+	ch := &fakeChannel{
+		confirms: make(chan amqp.Confirmation, 8),
+		returns:  make(chan amqp.Return, 8),
+	}
+	p := newConfirmPublisher(ch.open, 50*time.Millisecond)
+	require.NoError(t, p.reopen())
+	ctx := context.Background()
+
+	isUnavailable := func(err error) bool {
+		errResp, ok := err.(logging.ErrorResponse)
+		return ok && errResp.StatusCode() == http.StatusServiceUnavailable && errResp.ErrorCode == logging.ErrCodeUnavailable
+	}
+
+	assert.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+
+	ch.unroute = true
+	assert.True(t, isUnavailable(p.Publish(ctx, "de", "jobs.launches", []byte("{}"))))
+
+	ch.unroute, ch.nack = false, true
+	assert.True(t, isUnavailable(p.Publish(ctx, "de", "jobs.launches", []byte("{}"))))
+
+	// A publish that isn't confirmed in time fails, and its late confirmation
+	// doesn't answer the next publish.
+	ch.nack, ch.silent = false, true
+	assert.True(t, isUnavailable(p.Publish(ctx, "de", "jobs.launches", []byte("{}"))))
+	ch.confirms <- amqp.Confirmation{DeliveryTag: ch.tag, Ack: false}
+
+	ch.silent = false
+	assert.NoError(t, p.Publish(ctx, "de", "jobs.stops.1", []byte("{}")))
+	assert.Len(t, ch.keys, 5)
+
+	// A return for a publish that timed out, arriving while the next one
+	// waits, is matched by message ID and doesn't fail it.
+	ch.unroute, ch.silent = true, true
+	assert.True(t, isUnavailable(p.Publish(ctx, "de", "jobs.launches", []byte("{}"))))
+	ch.confirms <- amqp.Confirmation{DeliveryTag: ch.tag, Ack: true}
+	ch.late = []amqp.Return{{MessageId: ch.ids[len(ch.ids)-1], ReplyText: "NO_ROUTE"}}
+	ch.unroute, ch.silent = false, false
+	assert.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+	assert.NotEqual(t, ch.ids[len(ch.ids)-2], ch.ids[len(ch.ids)-1])
+
+	// A caller that gives up while waiting gets a retryable error too.
+	ch.silent = true
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err := p.Publish(cancelCtx, "de", "jobs.launches", []byte("{}"))
+	assert.True(t, isUnavailable(err))
+	ch.silent = false
+
+	// A confirm channel that's closed is reported the same way.
+	closed := make(chan amqp.Confirmation)
+	close(closed)
+	p = newConfirmPublisher(func() (*confirmChannel, error) {
+		return &confirmChannel{publishChannel: ch, confirms: closed, returns: ch.returns, closed: make(chan *amqp.Error)}, nil
+	}, time.Second)
+	require.NoError(t, p.reopen())
+	assert.True(t, isUnavailable(p.Publish(ctx, "de", "jobs.launches", []byte("{}"))))
+
+	// A timeout that would fail every publish is rejected.
+	_, timeoutErr := NewConfirmPublisher(nil, 0)
+	assert.Error(t, timeoutErr)
+
+	// The errors are retryable, so they mustn't stop the process.
+	amqpError(unavailable("the broker didn't confirm the message"))
+	amqpError(err)
+	amqpError(context.Canceled)
+}
+
+func TestConfirmPublisherReopens(t *testing.T) {
+	var (
+		opened   []*confirmChannel
+		openErr  error
+		channels []*fakeChannel
+	)
+	p := newConfirmPublisher(func() (*confirmChannel, error) {
+		if openErr != nil {
+			return nil, openErr
+		}
+		ch := &fakeChannel{
+			confirms: make(chan amqp.Confirmation, 8),
+			returns:  make(chan amqp.Return, 8),
+		}
+		cc, _ := ch.open()
+		channels = append(channels, ch)
+		opened = append(opened, cc)
+		return cc, nil
+	}, time.Second)
+	require.NoError(t, p.reopen())
+	ctx := context.Background()
+
+	require.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+	require.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+
+	// After a channel exception, the next publish goes out on a new channel,
+	// whose delivery tags start over.
+	opened[0].closed <- &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange"}
+	close(opened[0].closed)
+	require.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+	require.Len(t, channels, 2)
+	assert.Len(t, channels[0].keys, 2)
+	assert.Len(t, channels[1].keys, 1)
+
+	// A channel that can't be reopened fails the publish with a retryable
+	// error instead of stopping the process.
+	close(opened[1].closed)
+	openErr = amqp.ErrClosed
+	err := p.Publish(ctx, "de", "jobs.launches", []byte("{}"))
+	var errResp logging.ErrorResponse
+	if assert.ErrorAs(t, err, &errResp) {
+		assert.Equal(t, http.StatusServiceUnavailable, errResp.StatusCode())
+	}
+
+	openErr = nil
+	require.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+	assert.Len(t, channels, 3)
+}
+
+func TestConfirmPublisherTraceContext(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	ch := &fakeChannel{
+		confirms: make(chan amqp.Confirmation, 8),
+		returns:  make(chan amqp.Return, 8),
+	}
+	p := newConfirmPublisher(ch.open, time.Second)
+	require.NoError(t, p.reopen())
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	require.NoError(t, p.Publish(ctx, "de", "jobs.launches", []byte("{}")))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ch.headers[0]["traceparent"])
+}
+
+func TestAMQPErrorOnlyExitsForBrokerErrors(t *testing.T) {
+	// Failed quota checks and other errors that don't come from the broker
+	// are returned to the caller, including the scheduler, which records them
+	// on the scheduled launch. Any of these would end the test if they exited.
+	amqpError(errors.New("ipcdev has resource overages"))
+	amqpError(logging.NewStatusErrorResponse(http.StatusForbidden, logging.ErrCodeForbidden, "ipcdev has resource overages"))
+	amqpError(&amqp.Error{Code: 0, Reason: "not fatal"})
+}
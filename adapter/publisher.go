@@ -0,0 +1,220 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/messaging/v9"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+)
+
+// DefaultConfirmTimeout is how long to wait for the broker to confirm a publish
+// unless amqp.confirms.timeout says otherwise.
+const DefaultConfirmTimeout = 5 * time.Second
+
+// Publisher publishes messages to an exchange.
+type Publisher interface {
+	Publish(context context.Context, exchange, key string, body []byte) error
+}
+
+// publishChannel is the part of *amqp.Channel used by ConfirmPublisher.
+type publishChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// confirmChannel is a channel in confirm mode along with the Go channels that
+// the broker's confirmations and returns, and the channel's closing, are
+// delivered on.
+type confirmChannel struct {
+	publishChannel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	closed   chan *amqp.Error
+}
+
+// isClosed returns true once the channel has been closed, either by the broker
+// after a channel exception or along with the connection.
+func (c *confirmChannel) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConfirmPublisher publishes messages with the mandatory flag on a channel in
+// confirm mode, and waits for the broker to confirm each one. Messages that
+// the broker can't route are returned instead of being dropped. Each message
+// gets its own message ID so that returns can be matched to it. A returned or
+// nacked message, or one that isn't confirmed in time or before the context
+// is done, is reported as a 503 ErrorResponse so that the caller can try again.
+//
+// The broker closes the channel after a channel exception, such as a publish
+// to an exchange that doesn't exist, so a new channel is opened for the next
+// publish after that.
+type ConfirmPublisher struct {
+	mu      sync.Mutex
+	open    func() (*confirmChannel, error)
+	current *confirmChannel
+	timeout time.Duration
+	tag     uint64
+}
+
+// NewConfirmPublisher opens a channel in confirm mode on the connection and
+// returns a *ConfirmPublisher that publishes through it. The timeout must be
+// greater than zero.
+func NewConfirmPublisher(conn *amqp.Connection, timeout time.Duration) (*ConfirmPublisher, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("the publisher confirm timeout must be greater than zero, not %s", timeout)
+	}
+
+	p := newConfirmPublisher(func() (*confirmChannel, error) {
+		return openConfirmChannel(conn)
+	}, timeout)
+	if err := p.reopen(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newConfirmPublisher(open func() (*confirmChannel, error), timeout time.Duration) *ConfirmPublisher {
+	return &ConfirmPublisher{
+		open:    open,
+		timeout: timeout,
+	}
+}
+
+func openConfirmChannel(conn *amqp.Connection) (*confirmChannel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	// The channels are buffered so that the broker's replies never block the
+	// connection while a publish isn't waiting for them.
+	return &confirmChannel{
+		publishChannel: channel,
+		confirms:       channel.NotifyPublish(make(chan amqp.Confirmation, 8)),
+		returns:        channel.NotifyReturn(make(chan amqp.Return, 8)),
+		closed:         channel.NotifyClose(make(chan *amqp.Error, 1)),
+	}, nil
+}
+
+// reopen replaces the current channel with a new one. Delivery tags start
+// over on each channel.
+func (p *ConfirmPublisher) reopen() error {
+	ch, err := p.open()
+	if err != nil {
+		return err
+	}
+	p.current = ch
+	p.tag = 0
+	return nil
+}
+
+func unavailable(format string, args ...interface{}) error {
+	return logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, fmt.Sprintf(format, args...))
+}
+
+// Publish publishes the message and waits until the broker confirms it. One
+// message is published at a time so that confirmations can't be mixed up.
+func (p *ConfirmPublisher) Publish(context context.Context, exchange, key string, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current.isClosed() {
+		log.WithContext(context).Warn("reopening the publisher confirm channel, which was closed")
+		if err := p.reopen(); err != nil {
+			return unavailable("the channel for the message for %s couldn't be reopened: %s", key, err)
+		}
+	}
+	ch := p.current
+
+	// The W3C trace context goes in the headers, the same way the messaging
+	// client sends it.
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(context, messaging.AMQPHeaderCarrier(headers))
+
+	msg := amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		ContentType:  "text/plain",
+		MessageId:    uuid.New().String(),
+		Body:         body,
+	}
+
+	if err := ch.Publish(exchange, key, true, false, msg); err != nil {
+		if ch.isClosed() {
+			return unavailable("the channel closed before the message for %s was published", key)
+		}
+		return err
+	}
+	p.tag++
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ret := <-ch.returns:
+			// Late returns for publishes that timed out are skipped.
+			if ret.MessageId == msg.MessageId {
+				return unavailable("the message for %s couldn't be routed: %s", key, ret.ReplyText)
+			}
+
+		case conf, ok := <-ch.confirms:
+			if !ok {
+				return unavailable("the channel closed before the broker confirmed the message for %s", key)
+			}
+			// Late confirmations for publishes that timed out are skipped.
+			if conf.DeliveryTag < p.tag {
+				continue
+			}
+			// The broker sends a return before the confirmation of the
+			// same message, so it's already waiting if there is one.
+			if ret, returned := ch.returned(msg.MessageId); returned {
+				return unavailable("the message for %s couldn't be routed: %s", key, ret.ReplyText)
+			}
+			if !conf.Ack {
+				return unavailable("the broker rejected the message for %s", key)
+			}
+			return nil
+
+		case <-timer.C:
+			return unavailable("the broker didn't confirm the message for %s within %s", key, p.timeout)
+
+		case <-context.Done():
+			// The message may still be delivered, but the caller has
+			// stopped waiting, so it's reported as unconfirmed.
+			return unavailable("gave up waiting for the broker to confirm the message for %s: %s", key, context.Err())
+		}
+	}
+}
+
+// returned looks through the returns that are waiting for the one with the
+// message ID, discarding the ones left over from publishes that timed out.
+func (c *confirmChannel) returned(messageID string) (amqp.Return, bool) {
+	for {
+		select {
+		case ret := <-c.returns:
+			if ret.MessageId == messageID {
+				return ret, true
+			}
+		default:
+			return amqp.Return{}, false
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/submissions"
+	"github.com/cyverse-de/model/v6"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// RelaunchOverrides lists the settings that can be changed when a stored
+// submission is relaunched. Fields that aren't set keep their original values.
+// The resource settings apply to the container of every step.
+type RelaunchOverrides struct {
+	OutputDir          *string  `json:"output_dir,omitempty"`
+	CreateOutputSubdir *bool    `json:"create_output_subdir,omitempty"`
+	MinCPUCores        *float64 `json:"min_cpu_cores,omitempty"`
+	MaxCPUCores        *float64 `json:"max_cpu_cores,omitempty"`
+	MinMemoryLimit     *int64   `json:"min_memory_limit,omitempty"`
+	MemoryLimit        *int64   `json:"memory_limit,omitempty"`
+	MinDiskSpace       *int64   `json:"min_disk_space,omitempty"`
+}
+
+// RelaunchResponse is the response body of the relaunch handler.
+type RelaunchResponse struct {
+	InvocationID   string `json:"invocation_id"`
+	RelaunchedFrom string `json:"relaunched_from"`
+}
+
+func (o *RelaunchOverrides) validate() error {
+	var problem string
+
+	switch {
+	case o.MinCPUCores != nil && *o.MinCPUCores < 0,
+		o.MaxCPUCores != nil && *o.MaxCPUCores < 0,
+		o.MinMemoryLimit != nil && *o.MinMemoryLimit < 0,
+		o.MemoryLimit != nil && *o.MemoryLimit < 0,
+		o.MinDiskSpace != nil && *o.MinDiskSpace < 0:
+		problem = "resource overrides can't be negative"
+	case o.MinCPUCores != nil && o.MaxCPUCores != nil && *o.MaxCPUCores > 0 && *o.MinCPUCores > *o.MaxCPUCores:
+		problem = "min_cpu_cores can't be greater than max_cpu_cores"
+	case o.MinMemoryLimit != nil && o.MemoryLimit != nil && *o.MemoryLimit > 0 && *o.MinMemoryLimit > *o.MemoryLimit:
+		problem = "min_memory_limit can't be greater than memory_limit"
+	default:
+		return nil
+	}
+
+	return logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, problem)
+}
+
+// apply sets the overrides on a decoded job submission.
+func (o *RelaunchOverrides) apply(submission map[string]interface{}) error {
+	if o.OutputDir != nil {
+		submission["output_dir"] = *o.OutputDir
+	}
+	if o.CreateOutputSubdir != nil {
+		submission["create_output_subdir"] = *o.CreateOutputSubdir
+	}
+
+	resources := map[string]interface{}{}
+	if o.MinCPUCores != nil {
+		resources["min_cpu_cores"] = *o.MinCPUCores
+	}
+	if o.MaxCPUCores != nil {
+		resources["max_cpu_cores"] = *o.MaxCPUCores
+	}
+	if o.MinMemoryLimit != nil {
+		resources["min_memory_limit"] = *o.MinMemoryLimit
+	}
+	if o.MemoryLimit != nil {
+		resources["memory_limit"] = *o.MemoryLimit
+	}
+	if o.MinDiskSpace != nil {
+		resources["min_disk_space"] = *o.MinDiskSpace
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	steps, _ := submission["steps"].([]interface{})
+	for i, s := range steps {
+		step, ok := s.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("step %d of the stored submission isn't an object", i)
+		}
+		component, ok := step["component"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("step %d of the stored submission has no component", i)
+		}
+		container, ok := component["container"].(map[string]interface{})
+		if !ok {
+			container = map[string]interface{}{}
+			component["container"] = container
+		}
+		for k, v := range resources {
+			container[k] = v
+		}
+	}
+
+	return nil
+}
+
+// Relaunch launches the stored submission for a job again under a new
+// invocation ID, after applying the overrides. The new job is added to the DE
+// database before it's launched, with the overridden submission, so that it's
+// listed with the user's analyses and its millicores can be recorded. It then
+// goes through the same steps as any other launch, and its stored submission
+// is linked to the original job. Callers may only relaunch their own jobs
+// unless they're admins or may relaunch any job; the caller is taken from the
+// context. Returns a 404 ErrorResponse if no submission is stored
+// for the job or the DE database doesn't know it.
+func (j *JEXAdapter) Relaunch(context context.Context, invID string, overrides *RelaunchOverrides) (*model.Job, error) {
+	context = logging.WithJob(context, invID, "")
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "app relaunch"})
+
+	if j.subs == nil {
+		return nil, logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "submissions aren't being stored, so jobs can't be relaunched")
+	}
+	if j.db == nil {
+		return nil, logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "relaunched jobs can't be added to the DE database, so jobs can't be relaunched")
+	}
+
+	if overrides == nil {
+		overrides = &RelaunchOverrides{}
+	}
+	if err := overrides.validate(); err != nil {
+		return nil, err
+	}
+
+	// The caller is authorized before the 404 for missing submissions, so
+	// that it can't be used to find out which invocation IDs exist.
+	id := auth.IdentityFromContext(context)
+	restricted := id != nil && !id.Can(auth.PermAdmin) && !id.Can(auth.PermRelaunchAny)
+
+	sub, err := j.subs.Get(context, invID)
+	if restricted && (errors.Is(err, submissions.ErrNotFound) || err == nil && !id.IsUser(sub.Submitter)) {
+		return nil, logging.NewStatusErrorResponse(http.StatusForbidden, logging.ErrCodeForbidden, fmt.Sprintf("%s is not allowed to relaunch %s", id.Subject, invID))
+	}
+	if errors.Is(err, submissions.ErrNotFound) {
+		return nil, logging.NewStatusErrorResponse(http.StatusNotFound, logging.ErrCodeNotFound, fmt.Sprintf("no submission is stored for job %s", invID))
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	// Numbers are decoded as json.Number so that large integers, such as
+	// memory limits, survive the round trip unchanged.
+	var submission map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(sub.Raw))
+	decoder.UseNumber()
+	if err = decoder.Decode(&submission); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if err = overrides.apply(submission); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	newID := uuid.New().String()
+	submission["uuid"] = newID
+
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := j.Parse(body)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	// Parsing sanitizes the job name, so the DE database gets the one from
+	// the submission.
+	name, _ := submission["name"].(string)
+	if name == "" {
+		name = job.Name
+	}
+
+	err = j.db.CopyJob(context, invID, &db.JobCopy{
+		ExternalID:   newID,
+		Name:         name,
+		ResultFolder: job.OutputDirectory(),
+		Submission:   body,
+	})
+	if errors.Is(err, db.ErrJobNotFound) {
+		return nil, logging.NewStatusErrorResponse(http.StatusNotFound, logging.ErrCodeNotFound, fmt.Sprintf("job %s was not found", invID))
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	log.Infof("relaunching as %s", newID)
+	job, err = j.launch(context, body, job, invID)
+	if err != nil {
+		if delErr := j.db.DeleteJob(context, newID); delErr != nil {
+			log.Error(delErr)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// RelaunchHandler relaunches the stored submission for the job in the URL.
+// The optional request body contains RelaunchOverrides.
+func (j *JEXAdapter) RelaunchHandler(c echo.Context) error {
+	request := c.Request()
+	invID := c.Param("invocation_id")
+
+	log := log.WithFields(logrus.Fields{"context": "app relaunch"})
+
+	bodyBytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	overrides := &RelaunchOverrides{}
+	if len(bodyBytes) > 0 {
+		if err = json.Unmarshal(bodyBytes, overrides); err != nil {
+			return logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, fmt.Sprintf("unable to parse the overrides: %s", err))
+		}
+	}
+
+	job, err := j.Relaunch(request.Context(), invID, overrides)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RelaunchResponse{
+		InvocationID:   job.InvocationID,
+		RelaunchedFrom: invID,
+	})
+}
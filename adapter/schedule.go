@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/audit"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/scheduler"
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// LaunchValidator is implemented by messengers that can check whether a job
+// would be allowed to launch without launching it. Scheduled launches are
+// checked when they're submitted and again when they come due.
+type LaunchValidator interface {
+	ValidateLaunch(context context.Context, job *model.Job) error
+}
+
+// ScheduledLaunchResponse is the response body of the launch handlers when the
+// launch is scheduled for later.
+type ScheduledLaunchResponse struct {
+	InvocationID string    `json:"invocation_id"`
+	StartAt      time.Time `json:"start_at"`
+}
+
+// ScheduledLaunchesResponse is the response body of the handler that lists
+// pending launches.
+type ScheduledLaunchesResponse struct {
+	Launches []scheduler.Launch `json:"launches"`
+}
+
+// WithScheduler sets the store that holds launches scheduled for later.
+func WithScheduler(store *scheduler.Store) Option {
+	return func(j *JEXAdapter) {
+		j.sched = store
+	}
+}
+
+// startTime returns the time that the launch request asks for in its start_at
+// (RFC 3339) or delay (Go duration) query parameter. The zero time means that
+// the job should be launched right away.
+func startTime(c echo.Context) (time.Time, error) {
+	startAt, delay := c.QueryParam("start_at"), c.QueryParam("delay")
+
+	switch {
+	case startAt != "" && delay != "":
+		return time.Time{}, logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, "only one of start_at or delay can be set")
+	case startAt != "":
+		t, err := time.Parse(time.RFC3339, startAt)
+		if err != nil {
+			return time.Time{}, logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, "start_at must be an RFC 3339 timestamp")
+		}
+		return t, nil
+	case delay != "":
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return time.Time{}, logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, "delay must be a non-negative duration, such as 90m")
+		}
+		return time.Now().Add(d), nil
+	default:
+		return time.Time{}, nil
+	}
+}
+
+// Schedule parses a job submission and saves it to be launched at startAt. The
+// quota check is run now so that the caller finds out about overages early,
+// and it's run again when the launch comes due.
+func (j *JEXAdapter) Schedule(context context.Context, body []byte, startAt time.Time) (_ *model.Job, err error) {
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "app schedule"})
+
+	id := auth.IdentityFromContext(context)
+	rec := &audit.Record{Action: audit.ActionSchedule}
+	rec.SetIdentity(id)
+	defer func() {
+		j.audit(context, rec, err)
+	}()
+
+	if j.sched == nil {
+		return nil, logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "the scheduler isn't enabled, so launches can't be scheduled")
+	}
+
+	if latest := time.Now().Add(j.sched.MaxDelay()); startAt.After(latest) {
+		return nil, logging.NewStatusErrorResponse(
+			http.StatusBadRequest,
+			logging.ErrCodeBadRequest,
+			fmt.Sprintf("launches can't be scheduled more than %s in advance", j.sched.MaxDelay()),
+		)
+	}
+
+	job, err := j.Parse(body)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	submitter := username(body, job)
+	rec.InvocationID = job.InvocationID
+	rec.Submitter = submitter
+	rec.AppID = job.AppID
+
+	context = logging.WithJob(context, job.InvocationID, submitter)
+	log = log.WithContext(context)
+
+	if v, ok := j.messenger.(LaunchValidator); ok {
+		if err = v.ValidateLaunch(context, job); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	}
+
+	var caller string
+	if id != nil {
+		caller = id.Subject
+	}
+
+	err = j.sched.Schedule(context, job, submitter, body, caller, startAt)
+	if errors.Is(err, scheduler.ErrExists) {
+		return nil, logging.NewStatusErrorResponse(
+			http.StatusConflict,
+			logging.ErrCodeConflict,
+			fmt.Sprintf("a launch is already scheduled for job %s", job.InvocationID),
+		)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	log.Infof("scheduled the launch for %s", startAt.Format(time.RFC3339))
+	return job, nil
+}
+
+// CancelScheduled cancels a pending launch after making sure that the caller
+// is allowed to. Callers who may only stop their own jobs may only cancel
+// launches that they submitted. A nil identity skips the ownership check.
+func (j *JEXAdapter) CancelScheduled(context context.Context, id *auth.Identity, invID string) (err error) {
+	// The submitter is filled in from the scheduled launch, since the job
+	// isn't in the DE database or the job states yet.
+	rec := &audit.Record{Action: audit.ActionCancel, InvocationID: invID}
+	rec.SetIdentity(id)
+	defer func() {
+		j.audit(context, rec, err)
+	}()
+
+	context = logging.WithJob(context, invID, "")
+	log := log.WithContext(context).WithFields(logrus.Fields{"context": "cancel scheduled launch"})
+
+	if j.sched == nil {
+		return logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "the scheduler isn't enabled")
+	}
+
+	notFound := logging.NewStatusErrorResponse(
+		http.StatusNotFound,
+		logging.ErrCodeNotFound,
+		fmt.Sprintf("no launch is scheduled for job %s", invID),
+	)
+
+	launch, err := j.sched.Get(context, invID)
+	if errors.Is(err, scheduler.ErrNotFound) {
+		return notFound
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	rec.Submitter = launch.Submitter
+
+	if id != nil && !id.Can(auth.PermStopAny) && !(id.Can(auth.PermStopOwn) && id.IsUser(launch.Submitter)) {
+		return logging.NewStatusErrorResponse(
+			http.StatusForbidden,
+			logging.ErrCodeForbidden,
+			fmt.Sprintf("%s is not allowed to cancel %s", id.Subject, invID),
+		)
+	}
+
+	err = j.sched.Cancel(context, invID)
+	switch {
+	case errors.Is(err, scheduler.ErrNotFound):
+		return notFound
+	case errors.Is(err, scheduler.ErrNotPending):
+		return logging.NewStatusErrorResponse(
+			http.StatusConflict,
+			logging.ErrCodeConflict,
+			fmt.Sprintf("the launch of job %s is no longer pending", invID),
+		)
+	case err != nil:
+		log.Error(err)
+		return err
+	}
+
+	log.Info("canceled the scheduled launch")
+	return nil
+}
+
+// ScheduledHandler lists the pending launches. Callers who can't cancel
+// anyone's launches only see their own.
+func (j *JEXAdapter) ScheduledHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if j.sched == nil {
+		return logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "the scheduler isn't enabled")
+	}
+
+	var submitter string
+	if id := auth.FromContext(c); id != nil && !id.Can(auth.PermAdmin) && !id.Can(auth.PermStopAny) {
+		submitter = id.Subject
+	}
+
+	launches, err := j.sched.Pending(ctx, submitter)
+	if err != nil {
+		log.WithContext(ctx).Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ScheduledLaunchesResponse{Launches: launches})
+}
+
+// CancelScheduledHandler cancels the pending launch of the job in the URL.
+func (j *JEXAdapter) CancelScheduledHandler(c echo.Context) error {
+	if err := j.CancelScheduled(c.Request().Context(), auth.FromContext(c), c.Param("invocation_id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,237 @@
+// Package admin provides the administrative endpoints of the jex-adapter,
+// such as the ones that change the log level at runtime and temporarily
+// enable debug logging for a single submitter or job. The settings only apply
+// to the replica that handles the request unless they're shared through the
+// database with Settings.
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/types"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "admin"})
+
+const (
+	// DefaultDebugDuration is how long debug logging stays enabled for a
+	// target when the request doesn't say.
+	DefaultDebugDuration = time.Hour
+
+	// MaxDebugDuration is the longest that debug logging can be enabled for
+	// a target unless admin.debug.max_duration says otherwise.
+	MaxDebugDuration = 24 * time.Hour
+)
+
+// Admin contains the settings for the administrative endpoints.
+type Admin struct {
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+	settings        *Settings
+	replica         string
+}
+
+// Option configures optional parts of an *Admin.
+type Option func(*Admin)
+
+// WithSettings shares the log level and debug targets with the other replicas
+// through the database.
+func WithSettings(settings *Settings) Option {
+	return func(a *Admin) {
+		a.settings = settings
+	}
+}
+
+// New returns an *Admin configured by the admin.debug.default_duration and
+// admin.debug.max_duration settings.
+func New(cfg *viper.Viper, opts ...Option) *Admin {
+	a := &Admin{
+		defaultDuration: DefaultDebugDuration,
+		maxDuration:     MaxDebugDuration,
+	}
+	// The hostname is the pod name when running in Kubernetes.
+	a.replica, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(a)
+	}
+	if cfg != nil {
+		if cfg.IsSet("admin.debug.default_duration") {
+			a.defaultDuration = cfg.GetDuration("admin.debug.default_duration")
+		}
+		if cfg.IsSet("admin.debug.max_duration") {
+			a.maxDuration = cfg.GetDuration("admin.debug.max_duration")
+		}
+	}
+	return a
+}
+
+// Routes adds the administrative handlers to the router. Every route requires
+// the admin permission, which callers only have when authentication is
+// enabled.
+func (a *Admin) Routes(router types.Router) types.Router {
+	log := log.WithFields(logrus.Fields{"context": "adding admin routes"})
+
+	router.GET("/log-level", a.GetLogLevelHandler, auth.Require(auth.PermAdmin))
+	router.PUT("/log-level", a.SetLogLevelHandler, auth.Require(auth.PermAdmin))
+	log.Info("added handlers for /v1/admin/log-level")
+
+	router.PUT("/debug/submitters/:username", a.EnableDebugHandler(logging.SubmitterField, "username"), auth.Require(auth.PermAdmin))
+	router.DELETE("/debug/submitters/:username", a.DisableDebugHandler(logging.SubmitterField, "username"), auth.Require(auth.PermAdmin))
+	log.Info("added handlers for /v1/admin/debug/submitters/:username")
+
+	router.PUT("/debug/invocations/:invocation_id", a.EnableDebugHandler(logging.ExternalIDField, "invocation_id"), auth.Require(auth.PermAdmin))
+	router.DELETE("/debug/invocations/:invocation_id", a.DisableDebugHandler(logging.ExternalIDField, "invocation_id"), auth.Require(auth.PermAdmin))
+	log.Info("added handlers for /v1/admin/debug/invocations/:invocation_id")
+
+	return router
+}
+
+// LogLevel describes the log level and the active debug targets of the
+// replica that handled the request, and whether they're shared with the other
+// replicas.
+type LogLevel struct {
+	Level   string                `json:"level"`
+	Debug   []logging.DebugTarget `json:"debug"`
+	Replica string                `json:"replica"`
+	Shared  bool                  `json:"shared"`
+}
+
+func (a *Admin) currentLogLevel() LogLevel {
+	return LogLevel{
+		Level:   logging.Level().String(),
+		Debug:   logging.DebugTargets(),
+		Replica: a.replica,
+		Shared:  a.settings != nil,
+	}
+}
+
+// GetLogLevelHandler responds with the log level and the active debug
+// targets. Shared settings are picked up first, so that the response doesn't
+// depend on which replica handles it.
+func (a *Admin) GetLogLevelHandler(c echo.Context) error {
+	if a.settings != nil {
+		if err := a.settings.Sync(c.Request().Context()); err != nil {
+			return err
+		}
+	}
+	return c.JSON(http.StatusOK, a.currentLogLevel())
+}
+
+// SetLogLevelHandler changes the log level. The request body looks like
+// {"level": "debug"}.
+func (a *Admin) SetLogLevelHandler(c echo.Context) error {
+	var body struct {
+		Level string `json:"level"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return logging.NewErrorResponse(err)
+	}
+
+	level, ok := logging.ParseLevel(body.Level)
+	if !ok {
+		return logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, fmt.Sprintf("unknown log level %q", body.Level))
+	}
+
+	if a.settings != nil {
+		if err := a.settings.SetLevel(c.Request().Context(), level); err != nil {
+			return err
+		}
+	}
+
+	logging.SetLevel(level)
+	log.WithContext(c.Request().Context()).WithFields(logrus.Fields{
+		"context": "set log level",
+		"caller":  auth.FromContext(c).Subject,
+	}).Warnf("log level set to %s", level)
+
+	return c.JSON(http.StatusOK, a.currentLogLevel())
+}
+
+// EnableDebugHandler returns a handler that enables debug logging for the
+// entries whose field matches the path parameter. The optional request body
+// looks like {"duration": "30m"}.
+func (a *Admin) EnableDebugHandler(field, param string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		value := c.Param(param)
+
+		var body struct {
+			Duration string `json:"duration"`
+		}
+		if c.Request().ContentLength != 0 {
+			if err := c.Bind(&body); err != nil {
+				return logging.NewErrorResponse(err)
+			}
+		}
+
+		d, err := a.duration(body.Duration)
+		if err != nil {
+			return err
+		}
+
+		// If the target can't be shared, the next sync drops it again.
+		target := logging.EnableDebug(field, value, d)
+		if a.settings != nil {
+			if err = a.settings.EnableDebug(c.Request().Context(), target); err != nil {
+				return err
+			}
+		}
+		log.WithContext(c.Request().Context()).WithFields(logrus.Fields{
+			"context": "enable debug logging",
+			"caller":  auth.FromContext(c).Subject,
+		}).Warnf("debug logging enabled for %s %s until %s", field, target.Value, target.Expires.Format(time.RFC3339))
+
+		return c.JSON(http.StatusOK, target)
+	}
+}
+
+// DisableDebugHandler returns a handler that disables debug logging for the
+// entries whose field matches the path parameter.
+func (a *Admin) DisableDebugHandler(field, param string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		value := c.Param(param)
+
+		active := logging.DisableDebug(field, value)
+		if a.settings != nil {
+			var err error
+			if active, err = a.settings.DisableDebug(c.Request().Context(), field, value); err != nil {
+				return err
+			}
+		}
+		if !active {
+			return logging.NewStatusErrorResponse(http.StatusNotFound, logging.ErrCodeNotFound, fmt.Sprintf("debug logging isn't enabled for %s %s", field, value))
+		}
+
+		log.WithContext(c.Request().Context()).WithFields(logrus.Fields{
+			"context": "disable debug logging",
+			"caller":  auth.FromContext(c).Subject,
+		}).Warnf("debug logging disabled for %s %s", field, value)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// duration parses the requested duration, falling back to the default. It
+// returns a 400 ErrorResponse if the duration isn't positive or exceeds the
+// maximum.
+func (a *Admin) duration(requested string) (time.Duration, error) {
+	if requested == "" {
+		return a.defaultDuration, nil
+	}
+
+	d, err := time.ParseDuration(requested)
+	if err != nil {
+		return 0, logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, fmt.Sprintf("invalid duration %q", requested))
+	}
+	if d <= 0 || d > a.maxDuration {
+		return 0, logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, fmt.Sprintf("the duration must be greater than zero and no more than %s", a.maxDuration))
+	}
+	return d, nil
+}
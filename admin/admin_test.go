@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(method, target, key, body string) *httptest.ResponseRecorder {
+	authn := auth.New(true, map[string][]auth.Permission{
+		"admin":    {auth.PermAdmin},
+		"operator": {auth.PermLaunch, auth.PermStopAny},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "ops", Key: "admin-key", Roles: []string{"admin"}},
+		{Name: "apps", Key: "operator-key", Roles: []string{"operator"}},
+	}))
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(authn.Middleware())
+	New(nil).Routes(e.Group("/v1/admin"))
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// request sends a request with the admin key to routes added by the caller.
+func request(e *echo.Echo, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-API-Key", "admin-key")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLogLevel(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+	logging.SetLevel(logrus.InfoLevel)
+
+	rec := serve(http.MethodPut, "/v1/admin/log-level", "operator-key", `{"level": "debug"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, logrus.InfoLevel, logging.Level())
+
+	rec = serve(http.MethodPut, "/v1/admin/log-level", "admin-key", `{"level": "loud"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(http.MethodPut, "/v1/admin/log-level", "admin-key", `{"level": "debug"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, logrus.DebugLevel, logging.Level())
+
+	var level LogLevel
+	rec = serve(http.MethodGet, "/v1/admin/log-level", "admin-key", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	assert.Equal(t, "debug", level.Level)
+	assert.Empty(t, level.Debug)
+}
+
+func TestDisabledAuth(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+	logging.SetLevel(logrus.InfoLevel)
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	New(nil).Routes(e.Group("/v1/admin"))
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/admin/log-level", strings.NewReader(`{"level": "debug"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, logrus.InfoLevel, logging.Level())
+}
+
+func TestDebugTargets(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+
+	rec := serve(http.MethodPut, "/v1/admin/debug/submitters/ipcdev@iplantcollaborative.org", "admin-key", `{"duration": "48h"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(http.MethodPut, "/v1/admin/debug/submitters/ipcdev@iplantcollaborative.org", "admin-key", `{"duration": "30m"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var target logging.DebugTarget
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &target))
+	assert.Equal(t, logging.SubmitterField, target.Field)
+	assert.Equal(t, "ipcdev", target.Value)
+
+	rec = serve(http.MethodPut, "/v1/admin/debug/invocations/07b04ce2-7757-4b21-9e15-0b4c2f44be26", "admin-key", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var level LogLevel
+	rec = serve(http.MethodGet, "/v1/admin/log-level", "admin-key", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	assert.Len(t, level.Debug, 2)
+
+	rec = serve(http.MethodDelete, "/v1/admin/debug/submitters/ipcdev", "admin-key", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(http.MethodDelete, "/v1/admin/debug/submitters/ipcdev", "admin-key", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(http.MethodDelete, "/v1/admin/debug/invocations/07b04ce2-7757-4b21-9e15-0b4c2f44be26", "admin-key", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
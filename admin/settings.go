@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/cyverse-de/jex-adapter/admin"
+
+// Table is the name of the database table that holds the shared log settings.
+const Table = "jex_adapter_log_settings"
+
+// DefaultSyncInterval is how often each replica picks up the shared log
+// settings unless admin.log_settings.sync_interval says otherwise.
+const DefaultSyncInterval = 15 * time.Second
+
+// The log level is stored in the row with an empty field and value. Every
+// other row is a debug target.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		field text NOT NULL,
+		value text NOT NULL,
+		level text,
+		expires timestamp with time zone,
+		PRIMARY KEY (field, value)
+	)`,
+}
+
+// Settings keeps the log level and the debug targets in the database, so that
+// a change made through one replica reaches all of them.
+type Settings struct {
+	db *sqlx.DB
+}
+
+// NewSettings returns a *Settings that uses the database connection.
+func NewSettings(db *sqlx.DB) *Settings {
+	return &Settings{db: db}
+}
+
+// NewSettingsFromConfig returns a *Settings after creating the table if it
+// doesn't exist. It returns nil unless admin.log_settings.enabled is true, in
+// which case each replica keeps its own log settings.
+func NewSettingsFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB) (*Settings, error) {
+	if enabled, err := db.Setup(ctx, cfg, "admin.log_settings", conn, schema); !enabled {
+		return nil, err
+	}
+	return NewSettings(conn), nil
+}
+
+// SetLevel stores the log level.
+func (s *Settings) SetLevel(context context.Context, level logrus.Level) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "SetLevel")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (field, value, level)
+		VALUES ('', '', $1)
+		ON CONFLICT (field, value) DO UPDATE SET level = EXCLUDED.level
+	`
+
+	_, err := s.db.ExecContext(ctx, stmt, level.String())
+	return err
+}
+
+// EnableDebug stores a debug target, replacing the expiration time of the
+// target if it's already stored.
+func (s *Settings) EnableDebug(context context.Context, target logging.DebugTarget) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "EnableDebug")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (field, value, expires)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (field, value) DO UPDATE SET expires = EXCLUDED.expires
+	`
+
+	_, err := s.db.ExecContext(ctx, stmt, target.Field, logging.DebugValue(target.Field, target.Value), target.Expires)
+	return err
+}
+
+// DisableDebug removes a debug target. It returns false if the target wasn't
+// active.
+func (s *Settings) DisableDebug(context context.Context, field, value string) (bool, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "DisableDebug")
+	defer span.End()
+
+	const stmt = `
+		DELETE FROM ` + Table + `
+		WHERE field = $1 AND value = $2
+		RETURNING expires > now()
+	`
+
+	var active bool
+	err := s.db.QueryRowxContext(ctx, stmt, field, logging.DebugValue(field, value)).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+type setting struct {
+	Field   string     `db:"field"`
+	Value   string     `db:"value"`
+	Level   *string    `db:"level"`
+	Expires *time.Time `db:"expires"`
+}
+
+// Sync applies the stored log level and debug targets to this replica. The
+// level that the replica started with is kept until one is stored.
+func (s *Settings) Sync(context context.Context) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Sync")
+	defer span.End()
+
+	const query = `
+		SELECT field, value, level, expires
+		FROM ` + Table + `
+		WHERE field = '' OR expires > now()
+	`
+
+	var settings []setting
+	if err := s.db.SelectContext(ctx, &settings, query); err != nil {
+		return err
+	}
+
+	targets := []logging.DebugTarget{}
+	for _, st := range settings {
+		if st.Field == "" {
+			if st.Level == nil {
+				continue
+			}
+			if level, err := logrus.ParseLevel(*st.Level); err == nil && level != logging.Level() {
+				logging.SetLevel(level)
+			}
+			continue
+		}
+		if st.Expires != nil {
+			targets = append(targets, logging.DebugTarget{Field: st.Field, Value: st.Value, Expires: *st.Expires})
+		}
+	}
+	logging.ReplaceDebugTargets(targets)
+
+	return nil
+}
+
+// Run calls Sync at every interval until the context is canceled.
+func (s *Settings) Run(ctx context.Context, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "syncing log settings"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		return false, s.Sync(ctx)
+	})
+}
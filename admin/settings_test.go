@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var settingsColumns = []string{"field", "value", "level", "expires"}
+
+func TestNewSettingsFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+	cfg := viper.New()
+
+	settings, err := NewSettingsFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	assert.Nil(t, settings)
+
+	cfg.Set("admin.log_settings.enabled", true)
+	testutil.ExpectSchema(mock, schema)
+	settings, err = NewSettingsFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	assert.NotNil(t, settings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSettingsPostgres runs the settings against the schema in a real
+// database. It only runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestSettingsPostgres(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+	defer logging.ReplaceDebugTargets(nil)
+	logging.SetLevel(logrus.InfoLevel)
+
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("admin.log_settings.enabled", true)
+	settings, err := NewSettingsFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	target := logging.DebugTarget{Field: logging.ExternalIDField, Value: testutil.InvocationID, Expires: expires}
+	require.NoError(t, settings.SetLevel(ctx, logrus.WarnLevel))
+	require.NoError(t, settings.EnableDebug(ctx, target))
+
+	require.NoError(t, settings.Sync(ctx))
+	assert.Equal(t, logrus.WarnLevel, logging.Level())
+	require.Len(t, logging.DebugTargets(), 1)
+	assert.True(t, expires.Equal(logging.DebugTargets()[0].Expires))
+
+	active, err := settings.DisableDebug(ctx, target.Field, target.Value)
+	require.NoError(t, err)
+	assert.True(t, active)
+	active, err = settings.DisableDebug(ctx, target.Field, target.Value)
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	require.NoError(t, settings.Sync(ctx))
+	assert.Empty(t, logging.DebugTargets())
+}
+
+func TestSync(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+	defer logging.ReplaceDebugTargets(nil)
+	logging.SetLevel(logrus.InfoLevel)
+
+	conn, mock := testutil.MockDB(t)
+	settings := NewSettings(conn)
+
+	// The starting level is kept until one is stored.
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	mock.ExpectQuery("SELECT (.+) FROM " + Table).
+		WillReturnRows(sqlmock.NewRows(settingsColumns).
+			AddRow(logging.ExternalIDField, testutil.InvocationID, nil, expires))
+	require.NoError(t, settings.Sync(context.Background()))
+	assert.Equal(t, logrus.InfoLevel, logging.Level())
+	assert.Equal(t, []logging.DebugTarget{{Field: logging.ExternalIDField, Value: testutil.InvocationID, Expires: expires}}, logging.DebugTargets())
+
+	// Targets that were removed through another replica are dropped.
+	mock.ExpectQuery("SELECT (.+) FROM " + Table).
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow("", "", "warning", nil))
+	require.NoError(t, settings.Sync(context.Background()))
+	assert.Equal(t, logrus.WarnLevel, logging.Level())
+	assert.Empty(t, logging.DebugTargets())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharedSettings(t *testing.T) {
+	defer logging.SetLevel(logrus.InfoLevel)
+	defer logging.ReplaceDebugTargets(nil)
+	logging.SetLevel(logrus.InfoLevel)
+
+	conn, mock := testutil.MockDB(t)
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(true, map[string][]auth.Permission{"admin": {auth.PermAdmin}},
+		auth.NewAPIKeyAuthenticator([]auth.APIKey{{Name: "ops", Key: "admin-key", Roles: []string{"admin"}}})).Middleware())
+	New(nil, WithSettings(NewSettings(conn))).Routes(e.Group("/v1/admin"))
+
+	mock.ExpectExec("INSERT INTO " + Table).WithArgs("debug").WillReturnResult(sqlmock.NewResult(0, 1))
+	rec := request(e, http.MethodPut, "/v1/admin/log-level", `{"level": "debug"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, logrus.DebugLevel, logging.Level())
+
+	var level LogLevel
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, level.Replica)
+	assert.True(t, level.Shared)
+
+	// The level isn't changed on this replica if it can't be shared.
+	mock.ExpectExec("INSERT INTO " + Table).WithArgs("warning").WillReturnError(assert.AnError)
+	rec = request(e, http.MethodPut, "/v1/admin/log-level", `{"level": "warn"}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, logrus.DebugLevel, logging.Level())
+
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(logging.SubmitterField, "ipcdev", testutil.Within(time.Now().Add(time.Hour))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	rec = request(e, http.MethodPut, "/v1/admin/debug/submitters/ipcdev@iplantcollaborative.org", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The response reflects the shared settings, which another replica has
+	// changed since.
+	mock.ExpectQuery("SELECT (.+) FROM " + Table).
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow("", "", "info", nil))
+	rec = request(e, http.MethodGet, "/v1/admin/log-level", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	assert.Equal(t, "info", level.Level)
+	assert.Empty(t, level.Debug)
+
+	// Targets enabled through another replica can be disabled through this
+	// one.
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM "+Table)).
+		WithArgs(logging.ExternalIDField, testutil.InvocationID).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	rec = request(e, http.MethodDelete, "/v1/admin/debug/invocations/"+testutil.InvocationID, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM "+Table)).
+		WithArgs(logging.ExternalIDField, testutil.InvocationID).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}))
+	rec = request(e, http.MethodDelete, "/v1/admin/debug/invocations/"+testutil.InvocationID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
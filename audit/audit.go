@@ -0,0 +1,174 @@
+// Package audit keeps a durable record of the launch and stop requests handled
+// by the jex-adapter. Records are written to a database table and, optionally,
+// to an append-only JSON Lines file, and they can be searched through the
+// GET /v1/audit endpoint.
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "audit"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/audit"
+
+// The actions that are audited. A relaunch is a launch of a stored
+// submission under a new invocation ID. A schedule saves a launch to run
+// later, and a cancel cancels a scheduled launch before it runs.
+const (
+	ActionLaunch   = "launch"
+	ActionRelaunch = "relaunch"
+	ActionStop     = "stop"
+	ActionSchedule = "schedule"
+	ActionCancel   = "cancel"
+)
+
+// The outcomes of an audited request. A request is rejected when it fails
+// because of the caller, for example because the submission is invalid or the
+// caller isn't allowed to stop the job, and it fails otherwise.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// Record describes a single audited request.
+type Record struct {
+	ID                 int64     `json:"id,omitempty" db:"id"`
+	Timestamp          time.Time `json:"timestamp" db:"created_at"`
+	Action             string    `json:"action" db:"action"`
+	Caller             string    `json:"caller,omitempty" db:"caller"`
+	AuthMethod         string    `json:"auth_method,omitempty" db:"auth_method"`
+	InvocationID       string    `json:"invocation_id,omitempty" db:"invocation_id"`
+	Submitter          string    `json:"submitter,omitempty" db:"submitter"`
+	AppID              string    `json:"app_id,omitempty" db:"app_id"`
+	MillicoresReserved int64     `json:"millicores_reserved,omitempty" db:"millicores_reserved"`
+	Outcome            string    `json:"outcome" db:"outcome"`
+	ErrorCode          string    `json:"error_code,omitempty" db:"error_code"`
+	Error              string    `json:"error,omitempty" db:"error"`
+}
+
+// SetIdentity fills in the caller from an authenticated identity. A nil
+// identity, as used by the NATS interface, leaves the caller empty.
+func (r *Record) SetIdentity(id *auth.Identity) {
+	if id == nil {
+		return
+	}
+	r.Caller = id.Subject
+	r.AuthMethod = id.Method
+}
+
+// SetResult fills in the outcome, error code, and error message for the error
+// returned by the audited request. The message is redacted.
+func (r *Record) SetResult(err error) {
+	if err == nil {
+		r.Outcome = OutcomeSuccess
+		return
+	}
+
+	r.Outcome = OutcomeFailed
+	r.Error = logging.RedactString(err.Error())
+
+	var errResp logging.ErrorResponse
+	if errors.As(err, &errResp) {
+		r.ErrorCode = errResp.ErrorCode
+		r.Error = logging.RedactString(errResp.Message)
+		if status := errResp.StatusCode(); status >= 400 && status < 500 {
+			r.Outcome = OutcomeRejected
+		}
+	}
+}
+
+// Writer stores audit records.
+type Writer interface {
+	Write(ctx context.Context, rec *Record) error
+}
+
+// Querier searches stored audit records.
+type Querier interface {
+	Query(ctx context.Context, filter *Filter) ([]Record, error)
+}
+
+// Auditor writes audit records to each of its writers and searches them with
+// its querier.
+type Auditor struct {
+	querier Querier
+	writers []Writer
+}
+
+// New returns an *Auditor. The querier may be nil, in which case searches
+// fail with a 503 ErrorResponse.
+func New(querier Querier, writers ...Writer) *Auditor {
+	return &Auditor{
+		querier: querier,
+		writers: writers,
+	}
+}
+
+// NewFromConfig returns an *Auditor that writes to the audit table in the
+// database and, if audit.file is set, to a JSON Lines file. The table is
+// created if it doesn't exist. It returns nil unless audit.enabled is true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, dbconn *sqlx.DB) (*Auditor, error) {
+	if enabled, err := db.Setup(ctx, cfg, "audit", dbconn, schema); !enabled {
+		return nil, err
+	}
+
+	store := NewDBStore(dbconn)
+	writers := []Writer{store}
+	if path := cfg.GetString("audit.file"); path != "" {
+		file, err := NewFileWriter(path)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, file)
+	}
+
+	return New(store, writers...), nil
+}
+
+// Record writes the record to every writer, filling in the timestamp if it's
+// not set. Write failures are logged rather than returned so that an audit
+// outage doesn't stop jobs from launching.
+func (a *Auditor) Record(ctx context.Context, rec *Record) {
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+
+	for _, w := range a.writers {
+		if err := w.Write(ctx, rec); err != nil {
+			log.WithContext(ctx).WithFields(logrus.Fields{
+				"context": "writing audit record",
+				"action":  rec.Action,
+			}).Error(err)
+		}
+	}
+}
+
+// Query searches the stored records.
+func (a *Auditor) Query(ctx context.Context, filter *Filter) ([]Record, error) {
+	if a.querier == nil {
+		return nil, logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "audit records can't be searched")
+	}
+	return a.querier.Query(ctx, filter)
+}
+
+// Close closes the writers that need closing, such as the JSON Lines file.
+func (a *Auditor) Close() error {
+	var errs []error
+	for _, w := range a.writers {
+		if c, ok := w.(interface{ Close() error }); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockStore(t *testing.T) (*DBStore, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return NewDBStore(conn), mock
+}
+
+func TestSetResult(t *testing.T) {
+	r, err := logging.NewRedactor(logging.DefaultRedactedFields, []string{logging.EmailPattern})
+	require.NoError(t, err)
+	logging.SetRedactor(r)
+	defer func() {
+		r, _ = logging.NewRedactor(logging.DefaultRedactedFields, logging.DefaultRedactedPatterns)
+		logging.SetRedactor(r)
+	}()
+
+	rec := &Record{}
+	rec.SetResult(nil)
+	assert.Equal(t, OutcomeSuccess, rec.Outcome)
+
+	rec = &Record{}
+	rec.SetResult(logging.NewStatusErrorResponse(http.StatusForbidden, logging.ErrCodeForbidden, "ipcdev@example.org can't do that"))
+	assert.Equal(t, OutcomeRejected, rec.Outcome)
+	assert.Equal(t, logging.ErrCodeForbidden, rec.ErrorCode)
+	assert.Equal(t, "[REDACTED] can't do that", rec.Error)
+
+	rec = &Record{}
+	rec.SetResult(errors.New("connection refused"))
+	assert.Equal(t, OutcomeFailed, rec.Outcome)
+	assert.Empty(t, rec.ErrorCode)
+	assert.Equal(t, "connection refused", rec.Error)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	dbconn, mock := testutil.MockDB(t)
+
+	// The audit log is opt-in, so nothing touches the database unless it's
+	// enabled.
+	auditor, err := NewFromConfig(context.Background(), viper.New(), dbconn)
+	require.NoError(t, err)
+	assert.Nil(t, auditor)
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	cfg := viper.New()
+	cfg.Set("audit.enabled", true)
+	cfg.Set("audit.file", path)
+
+	testutil.ExpectSchema(mock, schema)
+
+	auditor, err = NewFromConfig(context.Background(), cfg, dbconn)
+	require.NoError(t, err)
+	require.NotNil(t, auditor)
+
+	// Records go to the table and the file.
+	mock.ExpectQuery("INSERT INTO " + Table).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	auditor.Record(context.Background(), &Record{Action: ActionLaunch, InvocationID: "one", Outcome: OutcomeSuccess})
+	require.NoError(t, auditor.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"invocation_id":"one"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDBStoreWriteAndQuery(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	rec := &Record{
+		Timestamp:          now,
+		Action:             ActionLaunch,
+		Caller:             "apps",
+		AuthMethod:         "api-key",
+		InvocationID:       "07b04ce2-7757-4b21-9e15-0b4c2f44be26",
+		Submitter:          "ipcdev",
+		AppID:              "c7f05682-23c8-4182-b9a2-e09650a5f49b",
+		MillicoresReserved: 4000,
+		Outcome:            OutcomeSuccess,
+	}
+
+	mock.ExpectQuery("INSERT INTO "+Table).
+		WithArgs(now, ActionLaunch, "apps", "api-key", rec.InvocationID, "ipcdev", rec.AppID, int64(4000), OutcomeSuccess, "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	require.NoError(t, store.Write(context.Background(), rec))
+	assert.Equal(t, int64(42), rec.ID)
+
+	start := now.Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE (caller = ANY($1) OR submitter = ANY($1)) AND outcome = $2 AND created_at >= $3")).
+		WithArgs("{\"ipcdev\",\"ipcdev@iplantcollaborative.org\"}", OutcomeSuccess, start, 10).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "action", "caller", "auth_method", "invocation_id",
+			"submitter", "app_id", "millicores_reserved", "outcome", "error_code", "error",
+		}).AddRow(42, now, ActionLaunch, "apps", "api-key", rec.InvocationID, "ipcdev", rec.AppID, 4000, OutcomeSuccess, "", ""))
+
+	records, err := store.Query(context.Background(), &Filter{User: "ipcdev", Outcome: OutcomeSuccess, Start: start, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{*rec}, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDBStorePostgres runs the store against the schema in a real database. It
+// only runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestDBStorePostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("audit.enabled", true)
+	auditor, err := NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+	defer auditor.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	for _, rec := range []*Record{
+		{Timestamp: now.Add(-time.Minute), Action: ActionLaunch, Caller: "apps", Submitter: "ipcdev@iplantcollaborative.org", InvocationID: "one", Outcome: OutcomeSuccess},
+		{Timestamp: now, Action: ActionStop, Caller: "ipcdev", InvocationID: "one", Outcome: OutcomeRejected, ErrorCode: "ERR_FORBIDDEN"},
+		{Timestamp: now, Action: ActionLaunch, Caller: "apps", Submitter: "ipcdev@example.org", InvocationID: "two", Outcome: OutcomeSuccess},
+	} {
+		require.NoError(t, auditor.querier.(*DBStore).Write(ctx, rec))
+		assert.NotZero(t, rec.ID)
+	}
+
+	// The user matches the caller or the submitter, with or without the DE
+	// user domain.
+	records, err := auditor.Query(ctx, &Filter{User: "ipcdev", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, ActionStop, records[0].Action)
+	assert.Equal(t, ActionLaunch, records[1].Action)
+
+	records, err = auditor.Query(ctx, &Filter{InvocationID: "one", Outcome: OutcomeSuccess, Start: now.Add(-time.Hour), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", records[0].Submitter)
+}
+
+func TestFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	w, err := NewFileWriter(path)
+	require.NoError(t, err)
+
+	a := New(nil, w)
+	a.Record(context.Background(), &Record{Action: ActionLaunch, InvocationID: "one", Outcome: OutcomeSuccess})
+	a.Record(context.Background(), &Record{Action: ActionStop, InvocationID: "two", Outcome: OutcomeRejected})
+	require.NoError(t, a.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, "one", records[0].InvocationID)
+	assert.False(t, records[0].Timestamp.IsZero())
+	assert.Equal(t, OutcomeRejected, records[1].Outcome)
+}
+
+type fakeQuerier struct {
+	filter *Filter
+}
+
+func (f *fakeQuerier) Query(_ context.Context, filter *Filter) ([]Record, error) {
+	f.filter = filter
+	return []Record{{ID: 1, Action: ActionStop, Outcome: OutcomeSuccess}}, nil
+}
+
+func TestQueryHandler(t *testing.T) {
+	q := &fakeQuerier{}
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(true, map[string][]auth.Permission{"admin": {auth.PermAdmin}},
+		auth.NewAPIKeyAuthenticator([]auth.APIKey{{Name: "ops", Key: "secret", Roles: []string{"admin"}}})).Middleware())
+	New(q).Routes(e.Group("/v1/audit"))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/v1/audit?user=ipcdev&app_id=wc&outcome=failed&start=2026-10-01T00:00:00Z&limit=5")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &Filter{
+		User:    "ipcdev",
+		AppID:   "wc",
+		Outcome: OutcomeFailed,
+		Start:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Limit:   5,
+	}, q.filter)
+
+	var body AuditResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Records, 1)
+
+	assert.Equal(t, http.StatusOK, serve("/v1/audit").Code)
+	assert.Equal(t, DefaultLimit, q.filter.Limit)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/audit?outcome=maybe").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/audit?start=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/audit?limit=5000").Code)
+
+	// The records aren't served when authentication is disabled.
+	e = echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	New(q).Routes(e.Group("/v1/audit"))
+	assert.Equal(t, http.StatusForbidden, serve("/v1/audit").Code)
+}
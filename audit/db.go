@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+)
+
+// Table is the name of the database table that holds the audit records.
+const Table = "jex_adapter_audit"
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		id bigserial PRIMARY KEY,
+		created_at timestamp with time zone NOT NULL DEFAULT now(),
+		action text NOT NULL,
+		caller text NOT NULL DEFAULT '',
+		auth_method text NOT NULL DEFAULT '',
+		invocation_id text NOT NULL DEFAULT '',
+		submitter text NOT NULL DEFAULT '',
+		app_id text NOT NULL DEFAULT '',
+		millicores_reserved bigint NOT NULL DEFAULT 0,
+		outcome text NOT NULL,
+		error_code text NOT NULL DEFAULT '',
+		error text NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_created_at_idx ON ` + Table + ` (created_at)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_submitter_idx ON ` + Table + ` (submitter)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_caller_idx ON ` + Table + ` (caller)`,
+}
+
+// DBStore writes audit records to the database and searches them.
+type DBStore struct {
+	db *sqlx.DB
+}
+
+// NewDBStore returns a *DBStore.
+func NewDBStore(db *sqlx.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Write inserts the record and sets its ID.
+func (s *DBStore) Write(context context.Context, rec *Record) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Write")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (
+			created_at, action, caller, auth_method, invocation_id, submitter,
+			app_id, millicores_reserved, outcome, error_code, error
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		)
+		RETURNING id
+	`
+
+	return s.db.QueryRowxContext(ctx, stmt,
+		rec.Timestamp,
+		rec.Action,
+		rec.Caller,
+		rec.AuthMethod,
+		rec.InvocationID,
+		rec.Submitter,
+		rec.AppID,
+		rec.MillicoresReserved,
+		rec.Outcome,
+		rec.ErrorCode,
+		rec.Error,
+	).Scan(&rec.ID)
+}
+
+// Filter limits the records returned by a search. Empty fields aren't used.
+type Filter struct {
+	// User matches either the caller or the submitter. Usernames match with
+	// or without the DE user domain, since callers authenticated by JWT don't
+	// carry it.
+	User         string
+	AppID        string
+	InvocationID string
+	Action       string
+	Outcome      string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// Query returns the records matching the filter, newest first.
+func (s *DBStore) Query(context context.Context, filter *Filter) ([]Record, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Query")
+	defer span.End()
+
+	query, args := filter.sql()
+
+	records := []Record{}
+	if err := s.db.SelectContext(ctx, &records, query, args...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// sql builds the search query and its arguments.
+func (f *Filter) sql() (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if f.User != "" {
+		add("(caller = ANY(?) OR submitter = ANY(?))", pq.StringArray{logging.ShortUsername(f.User), logging.QualifiedUsername(f.User)})
+	}
+	if f.AppID != "" {
+		add("app_id = ?", f.AppID)
+	}
+	if f.InvocationID != "" {
+		add("invocation_id = ?", f.InvocationID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Outcome != "" {
+		add("outcome = ?", f.Outcome)
+	}
+	if !f.Start.IsZero() {
+		add("created_at >= ?", f.Start)
+	}
+	if !f.End.IsZero() {
+		add("created_at < ?", f.End)
+	}
+
+	query := `
+		SELECT id, created_at, action, caller, auth_method, invocation_id,
+			submitter, app_id, millicores_reserved, outcome, error_code, error
+		FROM ` + Table
+
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, f.Limit)
+	query += fmt.Sprintf("\n\t\tORDER BY created_at DESC, id DESC\n\t\tLIMIT $%d", len(args))
+
+	return query, args
+}
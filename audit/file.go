@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileWriter appends audit records to a JSON Lines file, one record per line.
+type FileWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileWriter opens the file for appending, creating it if necessary.
+func NewFileWriter(path string) (*FileWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileWriter{file: file}, nil
+}
+
+// Write appends the record to the file. Each record is written with a single
+// call so that lines aren't interleaved.
+func (w *FileWriter) Write(_ context.Context, rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.file.Write(line)
+	return err
+}
+
+// Close closes the file.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/types"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLimit is the number of records returned when the request
+	// doesn't set a limit.
+	DefaultLimit = 100
+
+	// MaxLimit is the largest number of records returned by one request.
+	MaxLimit = 1000
+)
+
+// Routes adds the search handler to the router. It requires the admin
+// permission, which callers don't have when authentication is disabled.
+func (a *Auditor) Routes(router types.Router) types.Router {
+	log := log.WithFields(logrus.Fields{"context": "adding audit routes"})
+
+	router.GET("", a.QueryHandler, auth.Require(auth.PermAdmin))
+	router.GET("/", a.QueryHandler, auth.Require(auth.PermAdmin))
+	log.Info("added handler for GET /v1/audit")
+
+	return router
+}
+
+// AuditResponse is the response body of the search handler.
+type AuditResponse struct {
+	Records []Record `json:"records"`
+}
+
+// QueryHandler responds with the audit records matching the user, app_id,
+// invocation_id, action, outcome, start, end, and limit query parameters.
+// The start and end times are in RFC 3339 format.
+func (a *Auditor) QueryHandler(c echo.Context) error {
+	filter, err := filterFromRequest(c)
+	if err != nil {
+		return err
+	}
+
+	records, err := a.Query(c.Request().Context(), filter)
+	if err != nil {
+		log.WithContext(c.Request().Context()).Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, AuditResponse{Records: records})
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return logging.NewStatusErrorResponse(http.StatusBadRequest, logging.ErrCodeBadRequest, fmt.Sprintf(format, args...))
+}
+
+func filterFromRequest(c echo.Context) (*Filter, error) {
+	var err error
+
+	filter := &Filter{
+		User:         c.QueryParam("user"),
+		AppID:        c.QueryParam("app_id"),
+		InvocationID: c.QueryParam("invocation_id"),
+		Action:       c.QueryParam("action"),
+		Outcome:      c.QueryParam("outcome"),
+		Limit:        DefaultLimit,
+	}
+
+	switch filter.Action {
+	case "", ActionLaunch, ActionRelaunch, ActionStop, ActionSchedule, ActionCancel:
+	default:
+		return nil, badRequest("unknown action %q", filter.Action)
+	}
+
+	switch filter.Outcome {
+	case "", OutcomeSuccess, OutcomeRejected, OutcomeFailed:
+	default:
+		return nil, badRequest("unknown outcome %q", filter.Outcome)
+	}
+
+	for name, t := range map[string]*time.Time{"start": &filter.Start, "end": &filter.End} {
+		if v := c.QueryParam(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, badRequest("%s must be an RFC 3339 timestamp", name)
+			}
+		}
+	}
+
+	if v := c.QueryParam("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > MaxLimit {
+			return nil, badRequest("limit must be between 1 and %d", MaxLimit)
+		}
+	}
+
+	return filter, nil
+}
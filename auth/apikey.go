@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// APIKey is a static key that identifies a client service.
+type APIKey struct {
+	Name  string   `mapstructure:"name"`
+	Key   string   `mapstructure:"key"`
+	Roles []string `mapstructure:"roles"`
+}
+
+// APIKeyAuthenticator identifies callers by a static key passed in the
+// X-API-Key header or in an "Authorization: ApiKey <key>" header.
+type APIKeyAuthenticator struct {
+	keys []hashedKey
+}
+
+type hashedKey struct {
+	name  string
+	sum   [sha256.Size]byte
+	roles []string
+}
+
+// NewAPIKeyAuthenticator returns an *APIKeyAuthenticator for the given keys.
+func NewAPIKeyAuthenticator(keys []APIKey) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{}
+	for _, k := range keys {
+		if k.Key == "" {
+			log.WithField("context", "api keys").Warnf("ignoring API key %s with an empty value", k.Name)
+			continue
+		}
+		a.keys = append(a.keys, hashedKey{
+			name:  k.Name,
+			sum:   sha256.Sum256([]byte(k.Key)),
+			roles: k.Roles,
+		})
+	}
+	return a
+}
+
+// Name returns the name of the authentication method.
+func (a *APIKeyAuthenticator) Name() string {
+	return "api-key"
+}
+
+// Authenticate looks up the API key in the request.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(strings.ToLower(header), "apikey ") {
+			key = strings.TrimSpace(header[len("apikey "):])
+		}
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// Comparing digests in constant time keeps the lookup from leaking
+	// information about the configured keys.
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.sum[:]) == 1 {
+			return &Identity{
+				Subject: k.name,
+				Roles:   k.roles,
+			}, nil
+		}
+	}
+
+	return nil, errors.New("unknown API key")
+}
@@ -0,0 +1,298 @@
+// Package auth provides pluggable authentication and authorization for the
+// jex-adapter HTTP API. Callers can be identified by JWT bearer tokens, static
+// API keys, or mTLS client certificate subjects. Each identity is granted a
+// set of roles, and the roles map to the permissions checked by the routes.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "auth"})
+
+// Permission is an action that a caller may be allowed to perform.
+type Permission string
+
+const (
+	// PermLaunch allows a caller to launch jobs.
+	PermLaunch Permission = "launch"
+
+	// PermStopAny allows a caller to stop any job.
+	PermStopAny Permission = "stop-any"
+
+	// PermStopOwn allows a caller to stop jobs that they submitted.
+	PermStopOwn Permission = "stop-own"
+
+	// PermRelaunchAny allows a caller with PermLaunch to relaunch any user's
+	// job, rather than only the ones that they submitted.
+	PermRelaunchAny Permission = "relaunch-any"
+
+	// PermAdmin allows a caller to use the administrative endpoints, such as
+	// the ones that change the log level.
+	PermAdmin Permission = "admin"
+)
+
+// AllPermissions lists every permission known to the service.
+var AllPermissions = []Permission{PermLaunch, PermStopAny, PermStopOwn, PermRelaunchAny, PermAdmin}
+
+// ErrNoCredentials is returned by an Authenticator when the request doesn't
+// contain the kind of credentials that it handles.
+var ErrNoCredentials = errors.New("no credentials provided")
+
+// Authenticator identifies the caller making an HTTP request.
+type Authenticator interface {
+	// Name returns the name of the authentication method.
+	Name() string
+
+	// Authenticate returns the identity of the caller. It returns
+	// ErrNoCredentials if the request doesn't carry credentials for this
+	// method, and any other error if the credentials are invalid.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Identity describes an authenticated caller.
+type Identity struct {
+	Subject     string
+	Method      string
+	Roles       []string
+	permissions map[Permission]bool
+}
+
+// Can returns true if the identity has been granted the permission.
+func (i *Identity) Can(perm Permission) bool {
+	return i.permissions[perm]
+}
+
+// IsUser returns true if the identity's subject refers to the given DE
+// username. Usernames in the DE database usually carry the DE user domain, so
+// the comparison ignores it on either side, but not any other domain.
+func (i *Identity) IsUser(username string) bool {
+	return logging.SameUser(i.Subject, username)
+}
+
+const identityKey = "auth.identity"
+
+// FromContext returns the identity associated with the request, or nil if the
+// request hasn't been authenticated.
+func FromContext(c echo.Context) *Identity {
+	id, ok := c.Get(identityKey).(*Identity)
+	if !ok {
+		return nil
+	}
+	return id
+}
+
+type contextKey struct{}
+
+// NewContext returns a context that carries the identity, for code that's
+// shared by the HTTP, NATS, and gRPC interfaces and doesn't see the echo
+// context.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// IdentityFromContext returns the identity stored by NewContext, or nil if
+// there isn't one.
+func IdentityFromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(contextKey{}).(*Identity)
+	return id
+}
+
+// Auth contains the configured authenticators and role definitions.
+type Auth struct {
+	enabled        bool
+	authenticators []Authenticator
+	roles          map[string][]Permission
+}
+
+// New returns an *Auth that tries each of the authenticators in order. The
+// roles map role names to the permissions they grant.
+func New(enabled bool, roles map[string][]Permission, authenticators ...Authenticator) *Auth {
+	return &Auth{
+		enabled:        enabled,
+		authenticators: authenticators,
+		roles:          roles,
+	}
+}
+
+// NewFromConfig returns an *Auth built from the auth.* configuration settings.
+func NewFromConfig(cfg *viper.Viper) (*Auth, error) {
+	var (
+		err            error
+		authenticators []Authenticator
+	)
+
+	enabled := cfg.GetBool("auth.enabled")
+
+	roles := map[string][]Permission{}
+	for name, perms := range cfg.GetStringMapStringSlice("auth.roles") {
+		for _, p := range perms {
+			perm := Permission(p)
+			if !knownPermission(perm) {
+				return nil, fmt.Errorf("role %s has unknown permission %s", name, p)
+			}
+			roles[name] = append(roles[name], perm)
+		}
+	}
+
+	if cfg.GetString("auth.jwt.jwks_file") != "" {
+		var j *JWTAuthenticator
+		if j, err = NewJWTAuthenticator(&JWTConfig{
+			JWKSFile:      cfg.GetString("auth.jwt.jwks_file"),
+			Issuer:        cfg.GetString("auth.jwt.issuer"),
+			Audience:      cfg.GetString("auth.jwt.audience"),
+			UsernameClaim: cfg.GetString("auth.jwt.username_claim"),
+			RolesClaim:    cfg.GetString("auth.jwt.roles_claim"),
+		}); err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, j)
+	}
+
+	if cfg.IsSet("auth.api_keys") {
+		var keys []APIKey
+		if err = cfg.UnmarshalKey("auth.api_keys", &keys); err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, NewAPIKeyAuthenticator(keys))
+	}
+
+	if cfg.IsSet("auth.mtls.subjects") {
+		var subjects []ClientSubject
+		if err = cfg.UnmarshalKey("auth.mtls.subjects", &subjects); err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, NewMTLSAuthenticator(subjects))
+	}
+
+	if enabled && len(authenticators) == 0 {
+		return nil, errors.New("auth.enabled is true but no authentication methods are configured")
+	}
+
+	return New(enabled, roles, authenticators...), nil
+}
+
+func knownPermission(perm Permission) bool {
+	for _, p := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Auth) grant(id *Identity) {
+	id.permissions = map[Permission]bool{}
+	for _, role := range id.Roles {
+		for _, perm := range a.roles[role] {
+			id.permissions[perm] = true
+		}
+	}
+}
+
+// anonymous returns the identity of callers when authentication is disabled.
+// It has every permission except PermAdmin, so that the administrative
+// endpoints, which can change logging and expose audit records and stored
+// submissions, can't be used without authenticating.
+func anonymous() *Identity {
+	id := &Identity{
+		Subject:     "anonymous",
+		Method:      "none",
+		permissions: map[Permission]bool{},
+	}
+	for _, perm := range AllPermissions {
+		id.permissions[perm] = perm != PermAdmin
+	}
+	return id
+}
+
+// Identify returns the identity of the caller that sent the request. It
+// returns a nil identity and a nil error when the request has no credentials,
+// and an anonymous identity with every permission but PermAdmin when
+// authentication is disabled. Invalid credentials result in a 401 ErrorResponse.
+func (a *Auth) Identify(r *http.Request) (*Identity, error) {
+	if !a.enabled {
+		return anonymous(), nil
+	}
+
+	for _, authn := range a.authenticators {
+		id, err := authn.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			log.WithContext(r.Context()).WithFields(logrus.Fields{"context": "authentication", "method": authn.Name()}).Warn(err)
+			return nil, logging.NewStatusErrorResponse(http.StatusUnauthorized, logging.ErrCodeNotAuthorized, "invalid credentials")
+		}
+
+		id.Method = authn.Name()
+		a.grant(id)
+		return id, nil
+	}
+
+	return nil, nil
+}
+
+// Middleware returns echo middleware that identifies the caller and stores the
+// identity in the request context. Requests without credentials are passed
+// along unidentified so that routes like the liveness probe remain open; use
+// Require on routes that need an identity. When authentication is disabled,
+// every request is treated as an anonymous caller with every permission but
+// PermAdmin.
+func (a *Auth) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id, err := a.Identify(c.Request())
+			if err != nil {
+				return err
+			}
+			if id != nil {
+				c.Set(identityKey, id)
+				c.SetRequest(c.Request().WithContext(NewContext(c.Request().Context(), id)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// Check returns an ErrorResponse if the identity is nil or holds none of the
+// listed permissions. With no permissions listed, any identity is accepted.
+func Check(id *Identity, perms ...Permission) error {
+	if id == nil {
+		return logging.NewStatusErrorResponse(http.StatusUnauthorized, logging.ErrCodeNotAuthorized, "authentication required")
+	}
+
+	if len(perms) == 0 {
+		return nil
+	}
+
+	for _, perm := range perms {
+		if id.Can(perm) {
+			return nil
+		}
+	}
+
+	return logging.NewStatusErrorResponse(http.StatusForbidden, logging.ErrCodeForbidden, fmt.Sprintf("%s is not allowed to do that", id.Subject))
+}
+
+// Require returns route middleware that rejects unauthenticated requests and
+// requests from callers holding none of the listed permissions. With no
+// permissions listed, any authenticated caller is accepted.
+func Require(perms ...Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := Check(FromContext(c), perms...); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRoles = map[string][]Permission{
+	"launcher": {PermLaunch},
+	"operator": {PermLaunch, PermStopAny},
+	"user":     {PermStopOwn},
+}
+
+func writeJWKS(t *testing.T, key *rsa.PublicKey) string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	}
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// serve runs the request through the authentication middleware and a route
+// protected by the given permissions.
+func serve(a *Auth, req *http.Request, perms ...Permission) (*httptest.ResponseRecorder, *Identity) {
+	var seen *Identity
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(a.Middleware())
+	e.POST("/", func(c echo.Context) error {
+		seen = FromContext(c)
+		return c.NoContent(http.StatusOK)
+	}, Require(perms...))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec, seen
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	j, err := NewJWTAuthenticator(&JWTConfig{
+		JWKSFile: writeJWKS(t, &key.PublicKey),
+		Issuer:   "https://auth.example.org",
+	})
+	require.NoError(t, err)
+	a := New(true, testRoles, j)
+
+	token := signToken(t, key, jwt.MapClaims{
+		"iss":                "https://auth.example.org",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "ipcdev",
+		"realm_access":       map[string]interface{}{"roles": []string{"launcher", "unrelated"}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec, id := serve(a, req, PermLaunch)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "ipcdev", id.Subject)
+		assert.Equal(t, "jwt", id.Method)
+		assert.True(t, id.Can(PermLaunch))
+		assert.False(t, id.Can(PermStopAny))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec, _ = serve(a, req, PermStopAny)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	expired := signToken(t, key, jwt.MapClaims{
+		"iss":                "https://auth.example.org",
+		"exp":                time.Now().Add(-time.Hour).Unix(),
+		"preferred_username": "ipcdev",
+	})
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	rec, _ = serve(a, req, PermLaunch)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := signToken(t, otherKey, jwt.MapClaims{
+		"iss":                "https://auth.example.org",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "ipcdev",
+	})
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+forged)
+	rec, _ = serve(a, req, PermLaunch)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	a := New(true, testRoles, NewAPIKeyAuthenticator([]APIKey{
+		{Name: "apps", Key: "secret", Roles: []string{"operator"}},
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec, id := serve(a, req, PermStopAny)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "apps", id.Subject)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "ApiKey secret")
+	rec, _ = serve(a, req, PermLaunch)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-API-Key", "wrong")
+	rec, _ = serve(a, req, PermLaunch)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	rec, _ = serve(a, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestMTLSAuthentication(t *testing.T) {
+	a := New(true, testRoles, NewMTLSAuthenticator([]ClientSubject{
+		{Subject: "CN=apps,O=CyVerse", Roles: []string{"launcher"}},
+	}))
+
+	withCert := func(subject pkix.Name) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}},
+		}
+		return req
+	}
+
+	rec, id := serve(a, withCert(pkix.Name{CommonName: "apps", Organization: []string{"CyVerse"}}), PermLaunch)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "apps", id.Subject)
+	}
+
+	rec, _ = serve(a, withCert(pkix.Name{CommonName: "intruder"}), PermLaunch)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestDisabledAuthAllowsAllButAdmin(t *testing.T) {
+	a := New(false, testRoles)
+
+	rec, id := serve(a, httptest.NewRequest(http.MethodPost, "/", nil), PermStopAny)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "anonymous", id.Subject)
+	}
+
+	// The administrative endpoints stay closed.
+	rec, _ = serve(a, httptest.NewRequest(http.MethodPost, "/", nil), PermAdmin)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestIsUser(t *testing.T) {
+	id := &Identity{Subject: "ipcdev"}
+	assert.True(t, id.IsUser("ipcdev@iplantcollaborative.org"))
+	assert.True(t, id.IsUser("ipcdev"))
+	assert.False(t, id.IsUser("ipcdev2@iplantcollaborative.org"))
+
+	// Only the DE user domain is ignored.
+	assert.False(t, id.IsUser("ipcdev@example.org"))
+	id = &Identity{Subject: "alice@a.org"}
+	assert.False(t, id.IsUser("alice@b.org"))
+	assert.False(t, id.IsUser("alice"))
+	assert.True(t, id.IsUser("alice@a.org"))
+}
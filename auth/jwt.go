@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultUsernameClaim = "preferred_username"
+	defaultRolesClaim    = "realm_access.roles"
+)
+
+// JWTConfig contains the settings for validating JWT bearer tokens.
+type JWTConfig struct {
+	// JWKSFile is the path to a JSON Web Key Set containing the public keys
+	// used to verify token signatures.
+	JWKSFile string
+
+	// Issuer and Audience are checked against the iss and aud claims when set.
+	Issuer   string
+	Audience string
+
+	// UsernameClaim is the claim containing the caller's username.
+	UsernameClaim string
+
+	// RolesClaim is a dot-separated path to the claim containing the caller's
+	// roles, for example "realm_access.roles".
+	RolesClaim string
+}
+
+// JWTAuthenticator validates JWT bearer tokens against a local JWKS file. The
+// file is reloaded whenever its modification time changes so that key
+// rotation doesn't require a restart.
+type JWTAuthenticator struct {
+	cfg    JWTConfig
+	mutex  sync.RWMutex
+	keys   map[string]crypto.PublicKey
+	loaded time.Time
+}
+
+// NewJWTAuthenticator returns a *JWTAuthenticator after loading the JWKS file.
+func NewJWTAuthenticator(cfg *JWTConfig) (*JWTAuthenticator, error) {
+	c := *cfg
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = defaultUsernameClaim
+	}
+	if c.RolesClaim == "" {
+		c.RolesClaim = defaultRolesClaim
+	}
+
+	j := &JWTAuthenticator{cfg: c}
+	if err := j.reload(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Name returns the name of the authentication method.
+func (j *JWTAuthenticator) Name() string {
+	return "jwt"
+}
+
+// Authenticate validates the bearer token in the Authorization header.
+func (j *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(strings.ToLower(header), "bearer ") {
+		return nil, ErrNoCredentials
+	}
+	tokenString := strings.TrimSpace(header[len("bearer "):])
+
+	if err := j.reload(); err != nil {
+		log.WithField("context", "jwks reload").Error(err)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if j.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.cfg.Issuer))
+	}
+	if j.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.cfg.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, j.keyFunc, opts...); err != nil {
+		return nil, err
+	}
+
+	username, _ := claims[j.cfg.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("token is missing the %s claim", j.cfg.UsernameClaim)
+	}
+
+	return &Identity{
+		Subject: username,
+		Roles:   claimStrings(claims, j.cfg.RolesClaim),
+	}, nil
+}
+
+func (j *JWTAuthenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// claimStrings follows a dot-separated path through the claims and returns
+// the string values found at the end of it.
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	var current interface{} = map[string]interface{}(claims)
+
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+
+	var retval []string
+	switch v := current.(type) {
+	case string:
+		retval = append(retval, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				retval = append(retval, s)
+			}
+		}
+	}
+	return retval
+}
+
+func (j *JWTAuthenticator) reload() error {
+	info, err := os.Stat(j.cfg.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.RLock()
+	current := info.ModTime().Equal(j.loaded)
+	j.mutex.RUnlock()
+	if current {
+		return nil
+	}
+
+	data, err := os.ReadFile(j.cfg.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	j.keys = keys
+	j.loaded = info.ModTime()
+	j.mutex.Unlock()
+
+	log.WithField("context", "jwks reload").Infof("loaded %d keys from %s", len(keys), j.cfg.JWKSFile)
+
+	return nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found in the JWKS")
+	}
+
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ClientSubject maps the subject of a client certificate to a set of roles.
+type ClientSubject struct {
+	Subject string   `mapstructure:"subject"`
+	Roles   []string `mapstructure:"roles"`
+}
+
+// MTLSAuthenticator identifies callers by the subject of the verified client
+// certificate presented during the TLS handshake.
+type MTLSAuthenticator struct {
+	subjects map[string][]string
+}
+
+// NewMTLSAuthenticator returns an *MTLSAuthenticator for the given subjects.
+// Subjects are compared against the RFC 2253 form of the certificate subject,
+// for example "CN=apps,O=CyVerse".
+func NewMTLSAuthenticator(subjects []ClientSubject) *MTLSAuthenticator {
+	m := &MTLSAuthenticator{subjects: map[string][]string{}}
+	for _, s := range subjects {
+		m.subjects[s.Subject] = s.Roles
+	}
+	return m
+}
+
+// Name returns the name of the authentication method.
+func (m *MTLSAuthenticator) Name() string {
+	return "mtls"
+}
+
+// Authenticate checks the verified client certificate against the configured
+// subjects.
+func (m *MTLSAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	subject := cert.Subject.String()
+
+	roles, ok := m.subjects[subject]
+	if !ok {
+		return nil, fmt.Errorf("client certificate subject %s is not recognized", subject)
+	}
+
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = subject
+	}
+
+	return &Identity{
+		Subject: name,
+		Roles:   roles,
+	}, nil
+}
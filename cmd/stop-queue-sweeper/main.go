@@ -0,0 +1,73 @@
+// stop-queue-sweeper
+//
+// stop-queue-sweeper is a one-off command that deletes the per-job stop queues
+// that jex-adapter left behind before it started deleting them itself. It
+// finds the stop queues that have no consumers and have been idle for longer
+// than --idle-for through the RabbitMQ management API, and deletes them. Use
+// --dry-run to list them without deleting anything.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cyverse-de/configurate"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/stopqueues"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "main"})
+
+func main() {
+	var (
+		cfgPath       = flag.String("config", "", "Path to the jex-adapter configuration file")
+		managementURL = flag.String("management-url", "", "The URL of the RabbitMQ management API. Defaults to amqp.management_url in the configuration file.")
+		idleFor       = flag.Duration("idle-for", 7*24*time.Hour, "Only delete stop queues that have been idle for at least this long")
+		dryRun        = flag.Bool("dry-run", false, "List the stale stop queues without deleting them")
+		logLevel      = flag.String("log-level", "info", "One of trace, debug, info, warn, error, fatal, or panic.")
+	)
+
+	flag.Parse()
+	logging.SetupLogging(*logLevel, "text")
+
+	log := log.WithFields(logrus.Fields{"context": "main function"})
+
+	if *cfgPath == "" {
+		fmt.Println("--config is required")
+		flag.PrintDefaults()
+		os.Exit(-1)
+	}
+
+	c, err := configurate.InitDefaults(*cfgPath, configurate.JobServicesDefaults)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *managementURL == "" {
+		*managementURL = c.GetString("amqp.management_url")
+	}
+
+	sweeper, err := stopqueues.NewSweeper(*managementURL, c.GetString("amqp.uri"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	swept, err := sweeper.Sweep(context.Background(), *idleFor, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range swept {
+		fmt.Println(name)
+	}
+
+	if *dryRun {
+		log.Infof("found %d stop queues idle for more than %s", len(swept), *idleFor)
+	} else {
+		log.Infof("deleted %d stop queues idle for more than %s", len(swept), *idleFor)
+	}
+}
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/apd"
@@ -16,6 +19,9 @@ var log = logging.Log.WithFields(logrus.Fields{"package": "db"})
 
 const otelName = "github.com/cyverse-de/jex-adapter/db"
 
+// ErrJobNotFound is returned when a job can't be found by its external ID.
+var ErrJobNotFound = errors.New("job not found")
+
 // DatabaseAccessor is an interface for iteracting with a database. Its main
 // reason for existing is to abstract out whether a transaction is being used.
 type DatabaseAccessor interface {
@@ -94,3 +100,157 @@ func (d *Database) SetMillicoresReserved(context context.Context, externalID str
 
 	return err
 }
+
+// JobSubmitter returns the username of the user who submitted the job with
+// the given external ID. Returns ErrJobNotFound if no job step has that ID.
+func (d *Database) JobSubmitter(context context.Context, externalID string) (string, error) {
+	var username string
+
+	ctx, span := otel.Tracer(otelName).Start(context, "JobSubmitter")
+	defer span.End()
+
+	const query = `
+		SELECT u.username
+		FROM job_steps s
+		JOIN jobs j ON s.job_id = j.id
+		JOIN users u ON j.user_id = u.id
+		WHERE s.external_id = $1
+		LIMIT 1;
+	`
+
+	if err := d.db.QueryRowxContext(ctx, query, externalID).Scan(&username); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrJobNotFound
+		}
+		return "", err
+	}
+
+	return username, nil
+}
+
+// JobStatus returns the status recorded for the job with the given external
+// ID. Returns ErrJobNotFound if no job step has that ID.
+func (d *Database) JobStatus(context context.Context, externalID string) (string, error) {
+	var status string
+
+	ctx, span := otel.Tracer(otelName).Start(context, "JobStatus")
+	defer span.End()
+
+	const query = `
+		SELECT j.status
+		FROM job_steps s
+		JOIN jobs j ON s.job_id = j.id
+		WHERE s.external_id = $1
+		LIMIT 1;
+	`
+
+	if err := d.db.QueryRowxContext(ctx, query, externalID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrJobNotFound
+		}
+		return "", err
+	}
+
+	return status, nil
+}
+
+// JobCopy describes the job that CopyJob adds to the DE database.
+type JobCopy struct {
+	ExternalID   string
+	Name         string
+	ResultFolder string
+	Submission   []byte
+}
+
+// The columns of the DE jobs and job_steps tables that CopyJob copies from
+// the original job. The apps service owns those tables, so the tests check
+// these lists and the columns that CopyJob sets against its schema.
+var (
+	copiedJobColumns = []string{
+		"job_description", "app_name", "job_type_id", "app_id", "app_wiki_url",
+		"app_description", "notify", "user_id", "parent_id",
+	}
+	setJobColumns = []string{
+		"job_name", "result_folder_path", "submission", "start_date", "status",
+	}
+	copiedStepColumns = []string{
+		"step_number", "job_type_id", "app_step_number",
+	}
+	setStepColumns = []string{
+		"job_id", "external_id", "start_date", "status",
+	}
+)
+
+var copyJobStmt = fmt.Sprintf(`
+	WITH original AS (
+		SELECT job_id
+		FROM job_steps
+		WHERE external_id = $1
+		LIMIT 1
+	), copied AS (
+		INSERT INTO jobs (%[1]s, %[2]s)
+		SELECT j.%[3]s, $3, $4, $5, now(), 'Submitted'
+		FROM jobs j
+		JOIN original o ON j.id = o.job_id
+		RETURNING id
+	)
+	INSERT INTO job_steps (%[4]s, %[5]s)
+	SELECT s.%[6]s, c.id, $2, now(), 'Submitted'
+	FROM job_steps s
+	JOIN original o ON s.job_id = o.job_id
+	CROSS JOIN copied c
+	WHERE s.external_id = $1;
+`,
+	strings.Join(copiedJobColumns, ", "),
+	strings.Join(setJobColumns, ", "),
+	strings.Join(copiedJobColumns, ", j."),
+	strings.Join(copiedStepColumns, ", "),
+	strings.Join(setStepColumns, ", "),
+	strings.Join(copiedStepColumns, ", s."),
+)
+
+// CopyJob adds a job to the DE database for a relaunch of the job with the
+// external ID fromExternalID. The app, submitter, and parent are copied from
+// the original job, while the name, output folder, and submission are the
+// ones being launched. The new job is recorded as submitted now. Returns
+// ErrJobNotFound if no job step has fromExternalID.
+func (d *Database) CopyJob(context context.Context, fromExternalID string, job *JobCopy) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "CopyJob")
+	defer span.End()
+
+	result, err := d.db.ExecContext(ctx, copyJobStmt, fromExternalID, job.ExternalID, job.Name, job.ResultFolder, string(job.Submission))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// DeleteJob removes the job with the given external ID and its steps from the
+// DE database. It's used to clean up after CopyJob when the copied job can't
+// be launched.
+func (d *Database) DeleteJob(context context.Context, externalID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "DeleteJob")
+	defer span.End()
+
+	const stmt = `
+		WITH steps AS (
+			DELETE FROM job_steps
+			WHERE external_id = $1
+			RETURNING job_id
+		)
+		DELETE FROM jobs
+		WHERE id IN (SELECT job_id FROM steps);
+	`
+
+	_, err := d.db.ExecContext(ctx, stmt, externalID)
+	return err
+}
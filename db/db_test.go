@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/lib/pq"
+)
+
+func TestCopyJob(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	d := New(sqlx.NewDb(conn, "postgres"))
+	job := &JobCopy{
+		ExternalID:   "c654e8bb-d535-4f7a-bd0f-aff0f0c189b1",
+		Name:         "rerun",
+		ResultFolder: "/iplant/home/ipcdev/analyses/rerun",
+		Submission:   []byte(`{"name": "rerun"}`),
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs (job_description, app_name")).
+		WithArgs("07b04ce2-7757-4b21-9e15-0b4c2f44be26", job.ExternalID, job.Name, job.ResultFolder, string(job.Submission)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, d.CopyJob(context.Background(), "07b04ce2-7757-4b21-9e15-0b4c2f44be26", job))
+
+	mock.ExpectExec("INSERT INTO jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, d.CopyJob(context.Background(), "07b04ce2-7757-4b21-9e15-0b4c2f44be26", job), ErrJobNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCopyJobColumns checks the columns used by CopyJob against the schema of
+// a DE database. It only runs if JEX_ADAPTER_TEST_DE_DATABASE holds the URL
+// of one.
+func TestCopyJobColumns(t *testing.T) {
+	url := os.Getenv("JEX_ADAPTER_TEST_DE_DATABASE")
+	if url == "" {
+		t.Skip("JEX_ADAPTER_TEST_DE_DATABASE isn't set")
+	}
+
+	conn, err := sqlx.Connect("postgres", url)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for table, used := range map[string][]string{
+		"jobs":      append(append([]string{}, copiedJobColumns...), setJobColumns...),
+		"job_steps": append(append([]string{}, copiedStepColumns...), setStepColumns...),
+	} {
+		var columns []struct {
+			Name     string `db:"column_name"`
+			Required bool   `db:"required"`
+		}
+		err = conn.Select(&columns, `
+			SELECT column_name, is_nullable = 'NO' AND column_default IS NULL AS required
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1
+		`, table)
+		require.NoError(t, err)
+		require.NotEmpty(t, columns, table)
+
+		existing := map[string]bool{}
+		for _, c := range columns {
+			existing[c.Name] = true
+			if c.Required {
+				assert.Contains(t, used, c.Name, "%s.%s is required but isn't set by CopyJob", table, c.Name)
+			}
+		}
+		for _, name := range used {
+			assert.True(t, existing[name], "%s.%s doesn't exist", table, name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+)
+
+// SchemaLockID is the key of the advisory lock held while the jex-adapter's
+// own tables are created, so that replicas starting at the same time don't
+// race.
+const SchemaLockID = 0x6a6578736368 // "jexsch"
+
+// EnsureSchema runs the statements that create a table and its indexes in a
+// single transaction while holding the schema lock. The statements are
+// expected to be idempotent, such as CREATE TABLE IF NOT EXISTS.
+func EnsureSchema(context context.Context, conn *sqlx.DB, stmts []string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "EnsureSchema")
+	defer span.End()
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", SchemaLockID); err != nil {
+		return err
+	}
+
+	for _, stmt := range stmts {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSchema(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS things (id text PRIMARY KEY)`,
+		`CREATE INDEX IF NOT EXISTS things_id_idx ON things (id)`,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(SchemaLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS things").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE INDEX IF NOT EXISTS things_id_idx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, EnsureSchema(context.Background(), sqlx.NewDb(conn, "postgres"), stmts))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Nothing is committed if a statement fails.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS things").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, EnsureSchema(context.Background(), sqlx.NewDb(conn, "postgres"), stmts), assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
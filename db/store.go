@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The packages that keep their own state in the database, such as the
+// scheduler and the job states, share the lifecycle below. Each one is
+// opt-in through its <section>.enabled setting, creates its tables with Setup
+// when it's enabled, and does its periodic work, such as purging old rows,
+// with RunEvery.
+
+// Setup creates the tables of the store configured by the section, after
+// checking that <section>.enabled is true. It returns false without touching
+// the database if the store isn't enabled.
+func Setup(ctx context.Context, cfg *viper.Viper, section string, conn *sqlx.DB, schema []string) (bool, error) {
+	if !cfg.GetBool(section + ".enabled") {
+		return false, nil
+	}
+	if err := EnsureSchema(ctx, conn, schema); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Duration returns the duration setting, or def if it isn't set.
+func Duration(cfg *viper.Viper, key string, def time.Duration) time.Duration {
+	if cfg.IsSet(key) {
+		return cfg.GetDuration(key)
+	}
+	return def
+}
+
+// RunEvery calls work at every interval until the context is canceled. The
+// work returns true if there's more to do right away, such as when it handled
+// a full batch, in which case it's called again before waiting for the next
+// interval. Errors are logged, other than the ones caused by the context
+// being canceled.
+func RunEvery(ctx context.Context, interval time.Duration, log *logrus.Entry, work func(context.Context) (bool, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				more, err := work(ctx)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					log.Error(err)
+				}
+				if err != nil || !more {
+					break
+				}
+			}
+		}
+	}
+}
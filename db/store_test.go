@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+	dbconn := sqlx.NewDb(conn, "postgres")
+
+	stmts := []string{`CREATE TABLE IF NOT EXISTS things (id text PRIMARY KEY)`}
+
+	// Nothing touches the database unless the store is enabled.
+	enabled, err := Setup(context.Background(), viper.New(), "things", dbconn, stmts)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	cfg := viper.New()
+	cfg.Set("things.enabled", true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS things").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	enabled, err = Setup(context.Background(), cfg, "things", dbconn, stmts)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDuration(t *testing.T) {
+	cfg := viper.New()
+	assert.Equal(t, time.Hour, Duration(cfg, "things.ttl", time.Hour))
+	cfg.Set("things.ttl", "5m")
+	assert.Equal(t, 5*time.Minute, Duration(cfg, "things.ttl", time.Hour))
+}
+
+func TestRunEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The work is repeated right away while there's more to do, and stops
+	// at the first error.
+	calls := make(chan int, 10)
+	n := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunEvery(ctx, time.Millisecond, logrus.NewEntry(logrus.New()), func(context.Context) (bool, error) {
+			n++
+			calls <- n
+			switch n {
+			case 1, 2:
+				return true, nil
+			case 3:
+				return true, assert.AnError
+			default:
+				cancel()
+				return true, context.Canceled
+			}
+		})
+	}()
+
+	<-done
+	close(calls)
+	var got []int
+	for c := range calls {
+		got = append(got, c)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, got)
+}
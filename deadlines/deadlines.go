@@ -0,0 +1,286 @@
+// Package deadlines enforces wall-clock limits on jobs. When a job is launched
+// its time limit is stored in the database, and the clock starts once the job
+// is reported to be running, so that time spent waiting in the backend's queue
+// doesn't count against it. Once the deadline passes the job is stopped.
+// Keeping the deadlines in the database means that they survive restarts, and
+// expired deadlines are claimed with SELECT ... FOR UPDATE SKIP LOCKED and
+// pushed back before they're stopped, so that only one replica stops each
+// job.
+package deadlines
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "deadlines"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/deadlines"
+
+// Table is the name of the database table that holds the deadlines.
+const Table = "jex_adapter_deadlines"
+
+// Reason is the reason given in the stop requests sent for expired jobs.
+const Reason = "time limit exceeded"
+
+const (
+	// DefaultInterval is how often expired deadlines are looked for unless
+	// deadlines.interval says otherwise.
+	DefaultInterval = time.Minute
+
+	// batchSize is the largest number of deadlines claimed at once.
+	batchSize = 20
+
+	// maxAttempts is the number of times a stop request is sent for an
+	// expired job before giving up on it.
+	maxAttempts = 5
+)
+
+// The deadline is null until the job starts running. The time limit is kept
+// in seconds so that the deadline can be set then.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		invocation_id text PRIMARY KEY,
+		created_at timestamp with time zone NOT NULL DEFAULT now(),
+		deadline timestamp with time zone,
+		time_limit bigint NOT NULL DEFAULT 0,
+		submitter text NOT NULL DEFAULT '',
+		attempts integer NOT NULL DEFAULT 0,
+		error text NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_deadline_idx ON ` + Table + ` (deadline)`,
+}
+
+// Deadline is the time by which a job has to finish.
+type Deadline struct {
+	InvocationID string    `db:"invocation_id"`
+	Deadline     time.Time `db:"deadline"`
+	Submitter    string    `db:"submitter"`
+	Attempts     int       `db:"attempts"`
+}
+
+// Stopper stops a job whose deadline has passed. It's implemented by
+// *adapter.JEXAdapter.
+type Stopper interface {
+	Expire(ctx context.Context, invID, reason string) error
+}
+
+// Store saves deadlines and stops the jobs whose deadlines have passed.
+type Store struct {
+	db         *sqlx.DB
+	maxRuntime time.Duration
+}
+
+// New returns a *Store. A maxRuntime greater than zero limits every job to
+// that long; otherwise only the time limits in the jobs themselves apply.
+func New(db *sqlx.DB, maxRuntime time.Duration) *Store {
+	return &Store{
+		db:         db,
+		maxRuntime: maxRuntime,
+	}
+}
+
+// NewFromConfig returns a *Store configured by deadlines.max_runtime, after
+// creating the table if it doesn't exist. It returns nil unless
+// deadlines.enabled is true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB) (*Store, error) {
+	if enabled, err := db.Setup(ctx, cfg, "deadlines", conn, schema); !enabled {
+		return nil, err
+	}
+	return New(conn, db.Duration(cfg, "deadlines.max_runtime", 0)), nil
+}
+
+// Limit returns how long the job may run: the shorter of the configured
+// maximum runtime and the job's own time limit. The job's time limit is the
+// sum of the limits of its steps, which run one after another, and it only
+// applies if every step has one. Zero means that the job has no limit.
+func (s *Store) Limit(job *model.Job) time.Duration {
+	var jobLimit time.Duration
+	for _, step := range job.Steps {
+		if step.Component.TimeLimit <= 0 {
+			jobLimit = 0
+			break
+		}
+		jobLimit += time.Duration(step.Component.TimeLimit) * time.Second
+	}
+
+	switch {
+	case s.maxRuntime <= 0:
+		return jobLimit
+	case jobLimit <= 0 || s.maxRuntime < jobLimit:
+		return s.maxRuntime
+	default:
+		return jobLimit
+	}
+}
+
+// Set stores the deadline for a job that was just launched, if it has a time
+// limit, starting the clock right away. It's used when the job's status
+// updates aren't tracked, so there's no way to tell when it starts running.
+// The submitter is the DE username as it appears in the submission. It returns
+// the deadline, or the zero time if the
+// job has no limit.
+func (s *Store) Set(context context.Context, job *model.Job, submitter string) (time.Time, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Set")
+	defer span.End()
+
+	limit := s.Limit(job)
+	if limit <= 0 {
+		return time.Time{}, nil
+	}
+	deadline := time.Now().Add(limit)
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, deadline, time_limit, submitter)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (invocation_id) DO UPDATE SET deadline = EXCLUDED.deadline
+	`
+
+	if _, err := s.db.ExecContext(ctx, stmt, job.InvocationID, deadline, int64(limit/time.Second), submitter); err != nil {
+		return time.Time{}, err
+	}
+	return deadline, nil
+}
+
+// Pending stores the time limit of a job that was just launched, if it has
+// one, without starting the clock. The deadline is set by Start when the job
+// starts running. It returns the limit, or zero if the job has no limit.
+func (s *Store) Pending(context context.Context, job *model.Job, submitter string) (time.Duration, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Pending")
+	defer span.End()
+
+	limit := s.Limit(job)
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, time_limit, submitter)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	if _, err := s.db.ExecContext(ctx, stmt, job.InvocationID, int64(limit/time.Second), submitter); err != nil {
+		return 0, err
+	}
+	return limit, nil
+}
+
+// Start starts the clock for a job that's begun running and returns its
+// deadline. It returns the zero time if the job has no pending time limit,
+// either because it has none or because the clock was already started.
+func (s *Store) Start(context context.Context, invocationID string) (time.Time, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Start")
+	defer span.End()
+
+	const stmt = `
+		UPDATE ` + Table + `
+		SET deadline = now() + time_limit * interval '1 second'
+		WHERE invocation_id = $1
+		AND deadline IS NULL
+		RETURNING deadline
+	`
+
+	var deadline time.Time
+	err := s.db.QueryRowxContext(ctx, stmt, invocationID).Scan(&deadline)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return deadline, nil
+}
+
+// Remove deletes the deadline for a job that's been stopped or has finished.
+// It isn't an error if the job has no deadline.
+func (s *Store) Remove(context context.Context, invocationID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Remove")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+Table+` WHERE invocation_id = $1`, invocationID)
+	return err
+}
+
+// StopExpired stops the jobs whose deadlines have passed and returns the number
+// that were processed. The expired deadlines are claimed by pushing them back
+// by the retry delay before any stop request is sent, so that no row lock is
+// held while the stopper runs, since it may remove the deadline itself. A
+// deadline is removed once its stop request is sent. If the request
+// fails, it's tried again after the retry delay, up to maxAttempts times.
+func (s *Store) StopExpired(context context.Context, stopper Stopper, retry time.Duration) (int, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "StopExpired")
+	defer span.End()
+
+	const claim = `
+		WITH due AS (
+			SELECT invocation_id, deadline
+			FROM ` + Table + `
+			WHERE deadline <= now()
+			ORDER BY deadline
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE ` + Table + ` d
+		SET deadline = $2, attempts = d.attempts + 1
+		FROM due
+		WHERE d.invocation_id = due.invocation_id
+		RETURNING d.invocation_id, due.deadline, d.submitter, d.attempts
+	`
+
+	var expired []Deadline
+	if err := s.db.SelectContext(ctx, &expired, claim, batchSize, time.Now().Add(retry)); err != nil {
+		return 0, err
+	}
+
+	const remove = `DELETE FROM ` + Table + ` WHERE invocation_id = $1`
+	const failed = `UPDATE ` + Table + ` SET error = $2 WHERE invocation_id = $1`
+
+	for _, d := range expired {
+		ctx := auth.NewContext(ctx, &auth.Identity{Subject: "jex-adapter", Method: "deadline"})
+		ctx = logging.WithJob(ctx, d.InvocationID, d.Submitter)
+		log := log.WithContext(ctx).WithFields(logrus.Fields{"context": "enforcing deadline"})
+
+		var err error
+		stopErr := stopper.Expire(ctx, d.InvocationID, Reason)
+		switch {
+		case stopErr == nil:
+			log.Infof("stopped the job because its deadline of %s passed", d.Deadline.Format(time.RFC3339))
+			_, err = s.db.ExecContext(ctx, remove, d.InvocationID)
+		case d.Attempts >= maxAttempts:
+			log.Errorf("giving up on stopping the job after %d attempts: %s", maxAttempts, stopErr)
+			_, err = s.db.ExecContext(ctx, remove, d.InvocationID)
+		default:
+			log.Error(stopErr)
+			_, err = s.db.ExecContext(ctx, failed, d.InvocationID, logging.RedactString(stopErr.Error()))
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+// Run calls StopExpired at every interval until the context is canceled.
+// Failed stop requests are retried at the next interval.
+func (s *Store) Run(ctx context.Context, stopper Stopper, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "deadlines"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		// A full batch means that more deadlines may have passed.
+		n, err := s.StopExpired(ctx, stopper, interval)
+		return n == batchSize, err
+	})
+}
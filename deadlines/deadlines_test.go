@@ -0,0 +1,228 @@
+package deadlines
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invocationID = testutil.InvocationID
+
+var columns = []string{"invocation_id", "deadline", "submitter", "attempts"}
+
+func newMockStore(t *testing.T, maxRuntime time.Duration) (*Store, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return New(conn, maxRuntime), mock
+}
+
+func jobWithLimits(limits ...int) *model.Job {
+	job := &model.Job{InvocationID: invocationID, Submitter: "ipcdev"}
+	for _, limit := range limits {
+		step := model.Step{}
+		step.Component.TimeLimit = limit
+		job.Steps = append(job.Steps, step)
+	}
+	return job
+}
+
+type fakeStopper struct {
+	err     error
+	stopped []string
+	reasons []string
+	callers []string
+}
+
+func (f *fakeStopper) Expire(ctx context.Context, invID, reason string) error {
+	f.stopped = append(f.stopped, invID)
+	f.reasons = append(f.reasons, reason)
+	if id := auth.IdentityFromContext(ctx); id != nil {
+		f.callers = append(f.callers, id.Subject)
+	}
+	return f.err
+}
+
+func TestNewFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+
+	// Deadlines are opt-in, so nothing touches the database unless they're
+	// enabled.
+	store, err := NewFromConfig(context.Background(), viper.New(), conn)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	cfg := viper.New()
+	cfg.Set("deadlines.enabled", true)
+	cfg.Set("deadlines.max_runtime", "1h")
+	testutil.ExpectSchema(mock, schema)
+
+	store, err = NewFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	assert.Equal(t, time.Hour, store.Limit(jobWithLimits(0)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRuntime time.Duration
+		job        *model.Job
+		want       time.Duration
+	}{
+		{"no limits", 0, jobWithLimits(0), 0},
+		{"job limit only", 0, jobWithLimits(60, 120), 3 * time.Minute},
+		{"a step without a limit", 0, jobWithLimits(60, 0), 0},
+		{"configured limit only", time.Hour, jobWithLimits(0), time.Hour},
+		{"job limit is shorter", time.Hour, jobWithLimits(600), 10 * time.Minute},
+		{"configured limit is shorter", time.Hour, jobWithLimits(7200), time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, New(nil, tt.maxRuntime).Limit(tt.job))
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	store, mock := newMockStore(t, 0)
+
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, testutil.Within(time.Now().Add(time.Hour)), int64(3600), "ipcdev@iplantcollaborative.org").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	deadline, err := store.Set(context.Background(), jobWithLimits(3600), "ipcdev@iplantcollaborative.org")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+
+	// Jobs without a limit don't get a deadline.
+	deadline, err = store.Set(context.Background(), jobWithLimits(0), "ipcdev@iplantcollaborative.org")
+	require.NoError(t, err)
+	assert.True(t, deadline.IsZero())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPendingAndStart(t *testing.T) {
+	store, mock := newMockStore(t, 0)
+
+	// The limit is stored without starting the clock.
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, int64(3600), "ipcdev@iplantcollaborative.org").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	limit, err := store.Pending(context.Background(), jobWithLimits(3600), "ipcdev@iplantcollaborative.org")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, limit)
+
+	started := time.Now().Add(time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("AND deadline IS NULL")).
+		WithArgs(invocationID).
+		WillReturnRows(sqlmock.NewRows([]string{"deadline"}).AddRow(started))
+	deadline, err := store.Start(context.Background(), invocationID)
+	require.NoError(t, err)
+	assert.Equal(t, started, deadline)
+
+	// Starting the clock again doesn't move the deadline.
+	mock.ExpectQuery(regexp.QuoteMeta("AND deadline IS NULL")).
+		WithArgs(invocationID).
+		WillReturnRows(sqlmock.NewRows([]string{"deadline"}))
+	deadline, err = store.Start(context.Background(), invocationID)
+	require.NoError(t, err)
+	assert.True(t, deadline.IsZero())
+
+	// Jobs without a limit aren't stored.
+	limit, err = store.Pending(context.Background(), jobWithLimits(0), "ipcdev@iplantcollaborative.org")
+	require.NoError(t, err)
+	assert.Zero(t, limit)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStopExpired(t *testing.T) {
+	store, mock := newMockStore(t, 0)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).
+		WithArgs(batchSize, testutil.Within(time.Now().Add(time.Minute))).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(invocationID, time.Now(), "ipcdev", 1))
+	mock.ExpectExec("DELETE FROM " + Table).WithArgs(invocationID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	stopper := &fakeStopper{}
+	n, err := store.StopExpired(context.Background(), stopper, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{invocationID}, stopper.stopped)
+	assert.Equal(t, []string{Reason}, stopper.reasons)
+	assert.Equal(t, []string{"jex-adapter"}, stopper.callers)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStopExpiredRetries(t *testing.T) {
+	store, mock := newMockStore(t, 0)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("first", time.Now(), "ipcdev", 1).
+			AddRow("second", time.Now(), "ipcdev", maxAttempts))
+	mock.ExpectExec("UPDATE "+Table).
+		WithArgs("first", "broker unavailable").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM " + Table).WithArgs("second").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := store.StopExpired(context.Background(), &fakeStopper{err: errors.New("broker unavailable")}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres runs the store against the schema in a real database. It only
+// runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestPostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("deadlines.enabled", true)
+	store, err := NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	limit, err := store.Pending(ctx, jobWithLimits(60), "ipcdev@iplantcollaborative.org")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, limit)
+
+	deadline, err := store.Start(ctx, invocationID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+
+	// The clock is only started once.
+	deadline, err = store.Start(ctx, invocationID)
+	require.NoError(t, err)
+	assert.True(t, deadline.IsZero())
+
+	stopper := &fakeStopper{}
+	n, err := store.StopExpired(ctx, stopper, time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = conn.Exec(`UPDATE ` + Table + ` SET deadline = now() - interval '1 minute'`)
+	require.NoError(t, err)
+
+	n, err = store.StopExpired(ctx, stopper, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{invocationID}, stopper.stopped)
+
+	var remaining int
+	require.NoError(t, conn.Get(&remaining, `SELECT count(*) FROM `+Table))
+	assert.Zero(t, remaining)
+}
@@ -0,0 +1,285 @@
+// Package grpcapi exposes the jex-adapter API as a gRPC service. The service
+// and message definitions are in jexpb/jex_adapter.proto. The RPCs share the
+// business logic in the adapter and previewer packages with the HTTP API.
+//
+// The gRPC server only runs if jex-adapter is started with -grpc-addr, e.g.
+// -grpc-addr :60001. It uses the same TLS settings and API authentication as
+// the HTTP server.
+package grpcapi
+
+//go:generate buf generate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/grpcapi/jexpb"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/openapi"
+	"github.com/cyverse-de/jex-adapter/previewer"
+	"github.com/cyverse-de/model/v6"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "grpcapi"})
+
+// Adapter contains the job operations shared with the HTTP API. It's
+// implemented by *adapter.JEXAdapter.
+type Adapter interface {
+	Launch(ctx context.Context, body []byte) (*model.Job, error)
+	Stop(ctx context.Context, id *auth.Identity, invID string) error
+	Parse(body []byte) (*model.Job, error)
+	Status(ctx context.Context, id *auth.Identity, invID string) (string, error)
+}
+
+// Previewer renders command-line previews. It's implemented by
+// *previewer.Previewer.
+type Previewer interface {
+	Preview(params model.PreviewableStepParam, format string) (*previewer.Result, error)
+}
+
+// Server implements jexpb.JEXAdapterServer.
+type Server struct {
+	jexpb.UnimplementedJEXAdapterServer
+
+	adapter   Adapter
+	previewer Previewer
+	spec      *openapi.Spec
+	auth      *auth.Auth
+}
+
+// New returns a *Server.
+func New(a Adapter, p Previewer, spec *openapi.Spec, authn *auth.Auth) *Server {
+	return &Server{
+		adapter:   a,
+		previewer: p,
+		spec:      spec,
+		auth:      authn,
+	}
+}
+
+// NewGRPCServer returns a *grpc.Server with the service registered on it. TLS
+// is enabled when tlsConfig isn't nil. The trace context sent by the client is
+// picked up by the otelgrpc stats handler, which runs before the interceptors.
+func (s *Server) NewGRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.authenticate),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	jexpb.RegisterJEXAdapterServer(server, s)
+	return server
+}
+
+// authenticate identifies the caller with the same authenticators used by the
+// HTTP API. The credentials are taken from the request metadata and the
+// client certificate, so they're copied into a synthetic *http.Request.
+func (s *Server) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, info.FullMethod, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, name := range []string{"authorization", "x-api-key"} {
+			for _, value := range md.Get(name) {
+				r.Header.Add(name, value)
+			}
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &tlsInfo.State
+		}
+	}
+
+	id, err := s.auth.Identify(r)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return handler(auth.NewContext(ctx, id), req)
+}
+
+// Launch submits a job. The submission is checked against the OpenAPI
+// description first, the same way as on the HTTP API.
+func (s *Server) Launch(ctx context.Context, req *jexpb.LaunchRequest) (*jexpb.LaunchResponse, error) {
+	if err := auth.Check(auth.IdentityFromContext(ctx), auth.PermLaunch); err != nil {
+		return nil, toStatus(err)
+	}
+
+	fieldErrors, err := s.spec.ValidateBody("JobSubmission", req.GetJob())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if len(fieldErrors) > 0 {
+		return nil, toStatus(openapi.NewValidationError(fieldErrors))
+	}
+
+	job, err := s.adapter.Launch(ctx, req.GetJob())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &jexpb.LaunchResponse{InvocationId: job.InvocationID}, nil
+}
+
+// Stop sends a stop request for a job.
+func (s *Server) Stop(ctx context.Context, req *jexpb.StopRequest) (*jexpb.StopResponse, error) {
+	id := auth.IdentityFromContext(ctx)
+	if err := auth.Check(id, auth.PermStopAny, auth.PermStopOwn); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if req.GetInvocationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invocation_id is required")
+	}
+
+	if err := s.adapter.Stop(ctx, id, req.GetInvocationId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &jexpb.StopResponse{}, nil
+}
+
+// Preview renders the command-line arguments for a list of step parameters.
+func (s *Server) Preview(ctx context.Context, req *jexpb.PreviewRequest) (*jexpb.PreviewResponse, error) {
+	if err := auth.Check(auth.IdentityFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+
+	params := make(model.PreviewableStepParam, 0, len(req.GetParams()))
+	for _, p := range req.GetParams() {
+		params = append(params, model.StepParam{
+			ID:    p.GetId(),
+			Name:  p.GetName(),
+			Value: p.GetValue(),
+			Order: int(p.GetOrder()),
+			Type:  p.GetType(),
+			Path:  p.GetPath(),
+		})
+	}
+
+	result, err := s.previewer.Preview(params, req.GetFormat())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &jexpb.PreviewResponse{
+		Params: result.Params,
+		Format: result.Format,
+		Args:   result.Args,
+	}
+	for _, w := range result.Warnings {
+		resp.Warnings = append(resp.Warnings, &jexpb.LintWarning{
+			Index:   int32(w.Index),
+			Check:   w.Check,
+			Message: w.Message,
+		})
+	}
+
+	return resp, nil
+}
+
+// Validate checks a job submission against the OpenAPI description and the
+// job model without launching it.
+func (s *Server) Validate(ctx context.Context, req *jexpb.ValidateRequest) (*jexpb.ValidateResponse, error) {
+	if err := auth.Check(auth.IdentityFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+
+	fieldErrors, err := s.spec.ValidateBody("JobSubmission", req.GetJob())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if len(fieldErrors) == 0 {
+		if _, err = s.adapter.Parse(req.GetJob()); err != nil {
+			var errResp logging.ErrorResponse
+			message := err.Error()
+			if errors.As(err, &errResp) {
+				message = errResp.Message
+			}
+			fieldErrors = append(fieldErrors, openapi.FieldError{Location: "body", Message: message})
+		}
+	}
+
+	resp := &jexpb.ValidateResponse{Valid: len(fieldErrors) == 0}
+	for _, fe := range fieldErrors {
+		resp.Errors = append(resp.Errors, &jexpb.FieldError{
+			Location: fe.Location,
+			Field:    fe.Field,
+			Message:  fe.Message,
+		})
+	}
+
+	return resp, nil
+}
+
+// Status returns the status recorded for a job. Callers need the same
+// permissions as for stopping the job.
+func (s *Server) Status(ctx context.Context, req *jexpb.StatusRequest) (*jexpb.StatusResponse, error) {
+	id := auth.IdentityFromContext(ctx)
+	if err := auth.Check(id, auth.PermStopAny, auth.PermStopOwn); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if req.GetInvocationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invocation_id is required")
+	}
+
+	jobStatus, err := s.adapter.Status(ctx, id, req.GetInvocationId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &jexpb.StatusResponse{
+		InvocationId: req.GetInvocationId(),
+		Status:       jobStatus,
+	}, nil
+}
+
+// toStatus converts an error returned by the shared business logic into a
+// gRPC status, using the HTTP status code the REST API would have used.
+func toStatus(err error) error {
+	var errResp logging.ErrorResponse
+	if !errors.As(err, &errResp) {
+		log.Error(err)
+		return status.Error(codes.Internal, logging.RedactString(err.Error()))
+	}
+
+	var code codes.Code
+	switch errResp.StatusCode() {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+
+	return status.Error(code, logging.RedactString(errResp.Message))
+}
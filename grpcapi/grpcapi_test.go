@@ -0,0 +1,152 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/grpcapi/jexpb"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/openapi"
+	"github.com/cyverse-de/jex-adapter/previewer"
+	"github.com/cyverse-de/model/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeAdapter struct {
+	stopped string
+}
+
+func (f *fakeAdapter) Launch(_ context.Context, body []byte) (*model.Job, error) {
+	return &model.Job{InvocationID: "07b04ce2-7757-4b21-9e15-0b4c2f44be26"}, nil
+}
+
+func (f *fakeAdapter) Stop(_ context.Context, _ *auth.Identity, invID string) error {
+	f.stopped = invID
+	return nil
+}
+
+func (f *fakeAdapter) Parse(body []byte) (*model.Job, error) {
+	return &model.Job{}, nil
+}
+
+func (f *fakeAdapter) Status(_ context.Context, _ *auth.Identity, invID string) (string, error) {
+	if invID == "missing" {
+		return "", logging.NewStatusErrorResponse(404, logging.ErrCodeNotFound, "job missing was not found")
+	}
+	return "Running", nil
+}
+
+func newClient(t *testing.T, a *fakeAdapter, authn *auth.Auth) jexpb.JEXAdapterClient {
+	spec, err := openapi.New()
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := New(a, previewer.New(nil), spec, authn).NewGRPCServer(nil)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return jexpb.NewJEXAdapterClient(conn)
+}
+
+func submission(t *testing.T) []byte {
+	body, err := os.ReadFile("../test/test_submission.json")
+	require.NoError(t, err)
+	return body
+}
+
+func TestRPCs(t *testing.T) {
+	a := &fakeAdapter{}
+	client := newClient(t, a, auth.New(false, nil))
+	ctx := context.Background()
+
+	launched, err := client.Launch(ctx, &jexpb.LaunchRequest{Job: submission(t)})
+	require.NoError(t, err)
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", launched.GetInvocationId())
+
+	// Submissions that don't match the API description never reach the
+	// adapter.
+	_, err = client.Launch(ctx, &jexpb.LaunchRequest{Job: []byte(`{"username": "ipcdev", "steps": []}`)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Stop(ctx, &jexpb.StopRequest{InvocationId: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", a.stopped)
+
+	_, err = client.Stop(ctx, &jexpb.StopRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	preview, err := client.Preview(ctx, &jexpb.PreviewRequest{Params: []*jexpb.StepParam{
+		{Name: "-b", Value: "two", Order: 2},
+		{Name: "-a", Value: "one", Order: 1},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "-a one -b two", preview.GetParams())
+
+	jobStatus, err := client.Status(ctx, &jexpb.StatusRequest{InvocationId: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "Running", jobStatus.GetStatus())
+
+	_, err = client.Status(ctx, &jexpb.StatusRequest{InvocationId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestValidate(t *testing.T) {
+	client := newClient(t, &fakeAdapter{}, auth.New(false, nil))
+	ctx := context.Background()
+
+	resp, err := client.Validate(ctx, &jexpb.ValidateRequest{Job: submission(t)})
+	require.NoError(t, err)
+	assert.True(t, resp.GetValid())
+	assert.Empty(t, resp.GetErrors())
+
+	resp, err = client.Validate(ctx, &jexpb.ValidateRequest{Job: []byte(`{"username": "ipcdev", "steps": []}`)})
+	require.NoError(t, err)
+	assert.False(t, resp.GetValid())
+	assert.NotEmpty(t, resp.GetErrors())
+}
+
+func TestAuthentication(t *testing.T) {
+	authn := auth.New(true, map[string][]auth.Permission{"launcher": {auth.PermLaunch}}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "apps", Key: "secret", Roles: []string{"launcher"}},
+	}))
+	client := newClient(t, &fakeAdapter{}, authn)
+
+	_, err := client.Launch(context.Background(), &jexpb.LaunchRequest{Job: submission(t)})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong")
+	_, err = client.Launch(ctx, &jexpb.LaunchRequest{Job: submission(t)})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret")
+	_, err = client.Launch(ctx, &jexpb.LaunchRequest{Job: submission(t)})
+	assert.NoError(t, err)
+
+	_, err = client.Stop(ctx, &jexpb.StopRequest{InvocationId: "abc"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.Status(ctx, &jexpb.StatusRequest{InvocationId: "abc"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,341 @@
+// Package jobstatus follows the status updates that the job execution backends
+// publish on the jobs.updates routing key and keeps a state machine for each
+// invocation. The states are kept in the database so that both replicas see
+// every update, even though the updates are split between them by the shared
+// queue.
+package jobstatus
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/messaging/v9"
+	"github.com/cyverse-de/model/v6"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "jobstatus"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/jobstatus"
+
+// Table is the name of the database table that holds the job states.
+const Table = "jex_adapter_job_states"
+
+const (
+	// DefaultQueue is the name of the queue that job status updates are
+	// consumed from unless jobstatus.queue says otherwise. The replicas share
+	// it.
+	DefaultQueue = "jex-adapter.job-updates"
+
+	// DefaultRetention is how long the states of finished jobs are kept
+	// unless jobstatus.retention says otherwise.
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// DefaultPurgeInterval is how often the states of finished jobs are
+	// purged unless jobstatus.purge_interval says otherwise.
+	DefaultPurgeInterval = time.Hour
+
+	// RequeueDelay is how long the handler waits before requeueing an update
+	// that couldn't be stored. It doubles with each consecutive failure, up
+	// to MaxRequeueDelay, so that a database outage doesn't turn into a tight
+	// redelivery loop.
+	RequeueDelay = time.Second
+
+	// MaxRequeueDelay caps the delay before an update is requeued.
+	MaxRequeueDelay = time.Minute
+)
+
+// ErrNotFound is returned when a job's state isn't being tracked.
+var ErrNotFound = errors.New("job state not found")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		invocation_id text PRIMARY KEY,
+		state text NOT NULL,
+		submitter text NOT NULL DEFAULT '',
+		message text NOT NULL DEFAULT '',
+		stop_requested boolean NOT NULL DEFAULT false,
+		created_at timestamp with time zone NOT NULL DEFAULT now(),
+		updated_at timestamp with time zone NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_updated_at_idx ON ` + Table + ` (updated_at)`,
+}
+
+// JobState is the tracked state of a job.
+type JobState struct {
+	InvocationID  string    `json:"invocation_id" db:"invocation_id"`
+	State         State     `json:"state" db:"state"`
+	Submitter     string    `json:"submitter" db:"submitter"`
+	Message       string    `json:"message,omitempty" db:"message"`
+	StopRequested bool      `json:"stop_requested" db:"stop_requested"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Listener is told when a job starts running, so that its time limit can
+// start counting down, and when it reaches a terminal state, so that whatever
+// was held for it can be released. It's implemented by *adapter.JEXAdapter.
+type Listener interface {
+	Running(ctx context.Context, invID string)
+	Finished(ctx context.Context, invID string, state State)
+}
+
+// Tracker keeps the states of jobs.
+type Tracker struct {
+	db        *sqlx.DB
+	retention time.Duration
+
+	// requeueDelay is the delay before the first requeue, and failures is the
+	// number of consecutive updates that couldn't be stored.
+	requeueDelay time.Duration
+	failures     atomic.Int32
+}
+
+// New returns a *Tracker that keeps the states of finished jobs for the
+// retention period.
+func New(db *sqlx.DB, retention time.Duration) *Tracker {
+	return &Tracker{
+		db:           db,
+		retention:    retention,
+		requeueDelay: RequeueDelay,
+	}
+}
+
+// NewFromConfig returns a *Tracker configured by jobstatus.retention, after
+// creating the table if it doesn't exist. It returns nil unless
+// jobstatus.enabled is true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB) (*Tracker, error) {
+	if enabled, err := db.Setup(ctx, cfg, "jobstatus", conn, schema); !enabled {
+		return nil, err
+	}
+	return New(conn, db.Duration(cfg, "jobstatus.retention", DefaultRetention)), nil
+}
+
+// Submitted starts tracking a job that was just launched. The submitter is the
+// DE username as it appears in the submission. A job that's already tracked keeps its state, since an update may
+// have beaten the launch response.
+func (t *Tracker) Submitted(context context.Context, job *model.Job, submitter string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Submitted")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, state, submitter)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	_, err := t.db.ExecContext(ctx, stmt, job.InvocationID, Submitted, submitter)
+	return err
+}
+
+// Get returns the state of a job, or ErrNotFound if it isn't tracked.
+func (t *Tracker) Get(context context.Context, invocationID string) (*JobState, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Get")
+	defer span.End()
+
+	const query = `
+		SELECT invocation_id, state, submitter, message, stop_requested, created_at, updated_at
+		FROM ` + Table + `
+		WHERE invocation_id = $1
+	`
+
+	var js JobState
+	err := t.db.QueryRowxContext(ctx, query, invocationID).StructScan(&js)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &js, nil
+}
+
+// StopRequested notes that a stop request was sent for a job, so that its
+// failure is recorded as a cancellation.
+func (t *Tracker) StopRequested(context context.Context, invocationID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "StopRequested")
+	defer span.End()
+
+	const stmt = `
+		UPDATE ` + Table + `
+		SET stop_requested = true, updated_at = now()
+		WHERE invocation_id = $1
+	`
+
+	_, err := t.db.ExecContext(ctx, stmt, invocationID)
+	return err
+}
+
+// Apply moves a job to the state reported by a status update. It returns the
+// job's state afterward and whether the update changed it. Updates that would
+// move a job backward, or that arrive after it finished, are ignored.
+func (t *Tracker) Apply(context context.Context, update *messaging.UpdateMessage) (State, bool, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Apply")
+	defer span.End()
+
+	if update.Job == nil || update.Job.InvocationID == "" {
+		return "", false, errors.New("the job status update has no invocation ID")
+	}
+	invID := update.Job.InvocationID
+
+	tx, err := t.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	const query = `
+		SELECT state, stop_requested
+		FROM ` + Table + `
+		WHERE invocation_id = $1
+		FOR UPDATE
+	`
+
+	var (
+		current       State
+		stopRequested bool
+	)
+	err = tx.QueryRowxContext(ctx, query, invID).Scan(&current, &stopRequested)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", false, err
+	}
+
+	reported, ok := FromJobState(update.State, stopRequested)
+	if !ok {
+		return current, false, nil
+	}
+
+	next, changed := Next(current, reported)
+	if !changed {
+		return current, false, nil
+	}
+
+	const upsert = `
+		INSERT INTO ` + Table + ` (invocation_id, state, submitter, message)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (invocation_id) DO UPDATE
+		SET state = EXCLUDED.state, message = EXCLUDED.message, updated_at = now()
+	`
+
+	if _, err = tx.ExecContext(ctx, upsert, invID, next, update.Job.Submitter, logging.RedactString(update.Message)); err != nil {
+		return "", false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", false, err
+	}
+	return next, true, nil
+}
+
+// Handler returns the handler for job status update deliveries. The listener
+// is told about jobs that start running or finish. Updates that can't be parsed are dropped,
+// and ones that can't be stored are requeued after a delay that grows while
+// the failures continue.
+func (t *Tracker) Handler(listener Listener) messaging.MessageHandler {
+	return func(ctx context.Context, delivery amqp.Delivery) {
+		log := log.WithContext(ctx).WithFields(logrus.Fields{"context": "job status update"})
+
+		update := &messaging.UpdateMessage{}
+		if err := json.Unmarshal(delivery.Body, update); err != nil || update.Job == nil {
+			log.Errorf("dropping a job status update that can't be parsed: %v", err)
+			_ = delivery.Reject(false)
+			return
+		}
+
+		ctx = logging.WithJob(ctx, update.Job.InvocationID, update.Job.Submitter)
+		log = log.WithContext(ctx)
+
+		state, changed, err := t.Apply(ctx, update)
+		if err != nil {
+			log.Error(err)
+			t.backOff(ctx)
+			_ = delivery.Reject(true)
+			return
+		}
+		t.failures.Store(0)
+
+		if err = delivery.Ack(false); err != nil {
+			log.Error(err)
+		}
+
+		if !changed {
+			log.Debugf("ignored the %s update", update.State)
+			return
+		}
+
+		log.Infof("the job is now %s", state)
+		if listener == nil {
+			return
+		}
+		switch {
+		case state == Running:
+			listener.Running(ctx, update.Job.InvocationID)
+		case state.Terminal():
+			listener.Finished(ctx, update.Job.InvocationID, state)
+		}
+	}
+}
+
+// backOff waits before a failed update is requeued. The wait doubles with
+// each consecutive failure, up to MaxRequeueDelay, and ends early if the
+// context is done.
+func (t *Tracker) backOff(ctx context.Context) {
+	delay := t.requeueDelay
+	for n := t.failures.Add(1); n > 1 && delay < MaxRequeueDelay; n-- {
+		delay *= 2
+	}
+	if delay > MaxRequeueDelay {
+		delay = MaxRequeueDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Purge removes the states of jobs that finished longer ago than the retention
+// period and returns the number that were removed.
+func (t *Tracker) Purge(context context.Context) (int64, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Purge")
+	defer span.End()
+
+	const stmt = `
+		DELETE FROM ` + Table + `
+		WHERE state IN ($1, $2, $3)
+		AND updated_at < $4
+	`
+
+	result, err := t.db.ExecContext(ctx, stmt, Completed, Failed, Canceled, time.Now().Add(-t.retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunPurger calls Purge at every interval until the context is canceled.
+func (t *Tracker) RunPurger(ctx context.Context, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "purging job states"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		removed, err := t.Purge(ctx)
+		if removed > 0 {
+			log.Infof("removed the states of %d jobs that finished more than %s ago", removed, t.retention)
+		}
+		return false, err
+	})
+}
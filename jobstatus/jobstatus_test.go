@@ -0,0 +1,255 @@
+package jobstatus
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/cyverse-de/messaging/v9"
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invocationID = testutil.InvocationID
+
+func newMockTracker(t *testing.T) (*Tracker, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return New(conn, time.Hour), mock
+}
+
+func update(state messaging.JobState) *messaging.UpdateMessage {
+	return &messaging.UpdateMessage{
+		Job:     &model.Job{InvocationID: invocationID, Submitter: "ipcdev"},
+		State:   state,
+		Message: "status changed",
+	}
+}
+
+// acknowledger records how deliveries were acknowledged.
+type acknowledger struct {
+	acked, requeued, rejected int
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	if requeue {
+		a.requeued++
+	} else {
+		a.rejected++
+	}
+	return nil
+}
+
+type listener struct {
+	running  []string
+	finished map[string]State
+}
+
+func (l *listener) Running(ctx context.Context, invID string) {
+	l.running = append(l.running, invID)
+}
+
+func (l *listener) Finished(ctx context.Context, invID string, state State) {
+	l.finished[invID] = state
+}
+
+func TestNewFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+
+	// State tracking is opt-in, so nothing touches the database unless it's
+	// enabled.
+	tracker, err := NewFromConfig(context.Background(), viper.New(), conn)
+	require.NoError(t, err)
+	assert.Nil(t, tracker)
+
+	cfg := viper.New()
+	cfg.Set("jobstatus.enabled", true)
+	cfg.Set("jobstatus.retention", "2h")
+	testutil.ExpectSchema(mock, schema)
+
+	tracker, err = NewFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	require.NotNil(t, tracker)
+
+	// Finished jobs are purged once they're older than the retention.
+	mock.ExpectExec("DELETE FROM "+Table).
+		WithArgs(Completed, Failed, Canceled, testutil.Within(time.Now().Add(-2*time.Hour))).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	removed, err := tracker.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApply(t *testing.T) {
+	tracker, mock := newMockTracker(t)
+	selectState := regexp.QuoteMeta("FOR UPDATE")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectState).
+		WithArgs(invocationID).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "stop_requested"}).AddRow(Submitted, false))
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, Running, "ipcdev", "status changed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	state, changed, err := tracker.Apply(context.Background(), update(messaging.RunningState))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, Running, state)
+
+	// An update that arrives late doesn't move the job backward.
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectState).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "stop_requested"}).AddRow(Running, false))
+	mock.ExpectRollback()
+
+	state, changed, err = tracker.Apply(context.Background(), update(messaging.QueuedState))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, Running, state)
+
+	// A failure after a stop request is a cancellation.
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectState).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "stop_requested"}).AddRow(Running, true))
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, Canceled, "ipcdev", "status changed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	state, changed, err = tracker.Apply(context.Background(), update(messaging.FailedState))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, Canceled, state)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres runs the tracker against the schema in a real database. It only
+// runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestPostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("jobstatus.enabled", true)
+	tracker, err := NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	require.NoError(t, tracker.Submitted(ctx, update(messaging.SubmittedState).Job, "ipcdev@iplantcollaborative.org"))
+
+	state, changed, err := tracker.Apply(ctx, update(messaging.RunningState))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, Running, state)
+
+	// An update that arrives late doesn't move the job backward.
+	state, changed, err = tracker.Apply(ctx, update(messaging.QueuedState))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, Running, state)
+
+	require.NoError(t, tracker.StopRequested(ctx, invocationID))
+	state, _, err = tracker.Apply(ctx, update(messaging.FailedState))
+	require.NoError(t, err)
+	assert.Equal(t, Canceled, state)
+
+	js, err := tracker.Get(ctx, invocationID)
+	require.NoError(t, err)
+	assert.Equal(t, Canceled, js.State)
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", js.Submitter)
+	assert.True(t, js.StopRequested)
+
+	tracker.retention = -time.Minute
+	removed, err := tracker.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, err = tracker.Get(ctx, invocationID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestHandler(t *testing.T) {
+	tracker, mock := newMockTracker(t)
+	l := &listener{finished: map[string]State{}}
+	handler := tracker.Handler(l)
+
+	// The listener is told when the job starts running.
+	running, err := json.Marshal(update(messaging.RunningState))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "stop_requested"}).AddRow(Submitted, false))
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, Running, "ipcdev", "status changed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	handler(context.Background(), amqp.Delivery{Acknowledger: &acknowledger{}, Body: running})
+	assert.Equal(t, []string{invocationID}, l.running)
+	assert.Empty(t, l.finished)
+
+	body, err := json.Marshal(update(messaging.SucceededState))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "stop_requested"}))
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, Completed, "ipcdev", "status changed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ack := &acknowledger{}
+	handler(context.Background(), amqp.Delivery{Acknowledger: ack, Body: body})
+	assert.Equal(t, 1, ack.acked)
+	assert.Equal(t, map[string]State{invocationID: Completed}, l.finished)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Updates that can't be parsed are dropped.
+	ack = &acknowledger{}
+	handler(context.Background(), amqp.Delivery{Acknowledger: ack, Body: []byte("not json")})
+	assert.Equal(t, 1, ack.rejected)
+
+	// Updates that can't be stored are requeued after a delay that grows with
+	// each consecutive failure.
+	tracker.requeueDelay = 20 * time.Millisecond
+	for i, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond} {
+		mock.ExpectBegin().WillReturnError(assert.AnError)
+		ack = &acknowledger{}
+		start := time.Now()
+		handler(context.Background(), amqp.Delivery{Acknowledger: ack, Body: body})
+		assert.Equal(t, 1, ack.requeued)
+		assert.GreaterOrEqual(t, time.Since(start), want, "failure %d", i+1)
+	}
+	assert.Equal(t, int32(2), tracker.failures.Load())
+
+	// The wait ends when the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	tracker.requeueDelay = time.Hour
+	mock.ExpectBegin().WillReturnError(assert.AnError)
+	ack = &acknowledger{}
+	start := time.Now()
+	handler(ctx, amqp.Delivery{Acknowledger: ack, Body: body})
+	assert.Equal(t, 1, ack.requeued)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,79 @@
+package jobstatus
+
+import "github.com/cyverse-de/messaging/v9"
+
+// State is the state of a job as tracked by the jex-adapter.
+type State string
+
+// The states that a job goes through. Completed, Failed, and Canceled are
+// terminal; once a job reaches one of them its state no longer changes.
+const (
+	Submitted State = "Submitted"
+	Queued    State = "Queued"
+	Running   State = "Running"
+	Completed State = "Completed"
+	Failed    State = "Failed"
+	Canceled  State = "Canceled"
+)
+
+// rank orders the states so that updates that arrive out of order can't move
+// a job backward.
+func (s State) rank() int {
+	switch s {
+	case Submitted:
+		return 1
+	case Queued:
+		return 2
+	case Running:
+		return 3
+	case Completed, Failed, Canceled:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Terminal returns true if the job has finished.
+func (s State) Terminal() bool {
+	return s.rank() == 4
+}
+
+// Next returns the state that a job in the current state moves to when an
+// update reports the given state, and whether that's a change. An empty
+// current state means that the job isn't being tracked yet.
+func Next(current, update State) (State, bool) {
+	if update.rank() == 0 {
+		return current, false
+	}
+	if current == "" {
+		return update, true
+	}
+	if current.Terminal() || update.rank() <= current.rank() {
+		return current, false
+	}
+	return update, true
+}
+
+// FromJobState converts the state in a job status update into a State. The
+// backends report a job that was stopped on request as Failed, so a failure
+// after a stop request was sent counts as a cancellation. The second return
+// value is false for states that aren't tracked.
+func FromJobState(js messaging.JobState, stopRequested bool) (State, bool) {
+	switch js {
+	case messaging.SubmittedState:
+		return Submitted, true
+	case messaging.QueuedState:
+		return Queued, true
+	case messaging.RunningState, messaging.ImpendingCancellationState:
+		return Running, true
+	case messaging.SucceededState:
+		return Completed, true
+	case messaging.FailedState:
+		if stopRequested {
+			return Canceled, true
+		}
+		return Failed, true
+	default:
+		return "", false
+	}
+}
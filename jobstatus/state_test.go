@@ -0,0 +1,66 @@
+package jobstatus
+
+import (
+	"testing"
+
+	"github.com/cyverse-de/messaging/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		current, update, want State
+		changed               bool
+	}{
+		{"", Submitted, Submitted, true},
+		{"", Running, Running, true},
+		{Submitted, Queued, Queued, true},
+		{Queued, Running, Running, true},
+		{Running, Completed, Completed, true},
+		{Submitted, Failed, Failed, true},
+		{Running, Queued, Running, false},
+		{Running, Running, Running, false},
+		{Completed, Failed, Completed, false},
+		{Canceled, Running, Canceled, false},
+		{Running, "Bogus", Running, false},
+	}
+
+	for _, tt := range tests {
+		got, changed := Next(tt.current, tt.update)
+		assert.Equal(t, tt.want, got, "%q -> %q", tt.current, tt.update)
+		assert.Equal(t, tt.changed, changed, "%q -> %q", tt.current, tt.update)
+	}
+}
+
+func TestFromJobState(t *testing.T) {
+	tests := []struct {
+		js            messaging.JobState
+		stopRequested bool
+		want          State
+		ok            bool
+	}{
+		{messaging.SubmittedState, false, Submitted, true},
+		{messaging.QueuedState, false, Queued, true},
+		{messaging.RunningState, false, Running, true},
+		{messaging.ImpendingCancellationState, false, Running, true},
+		{messaging.SucceededState, true, Completed, true},
+		{messaging.FailedState, false, Failed, true},
+		{messaging.FailedState, true, Canceled, true},
+		{messaging.JobState("Held"), false, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := FromJobState(tt.js, tt.stopRequested)
+		assert.Equal(t, tt.want, got, tt.js)
+		assert.Equal(t, tt.ok, ok, tt.js)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	for _, s := range []State{Completed, Failed, Canceled} {
+		assert.True(t, s.Terminal(), s)
+	}
+	for _, s := range []State{Submitted, Queued, Running, ""} {
+		assert.False(t, s.Terminal(), s)
+	}
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Field names added to entries that are logged with a context.
+const (
+	TraceIDField    = "trace_id"
+	SpanIDField     = "span_id"
+	ExternalIDField = "external_id"
+	SubmitterField  = "submitter"
+)
+
+type fieldsKey struct{}
+
+// WithJob returns a context that carries the external ID and submitter of a
+// job. Entries logged with the context, for example through
+// log.WithContext(ctx), include them as fields. Empty values are ignored. The
+// submitter is kept as given, since the redactor leaves SubmitterField alone.
+func WithJob(ctx context.Context, externalID, submitter string) context.Context {
+	fields := logrus.Fields{}
+	for k, v := range fieldsFromContext(ctx) {
+		fields[k] = v
+	}
+	if externalID != "" {
+		fields[ExternalIDField] = externalID
+	}
+	if submitter != "" {
+		fields[SubmitterField] = submitter
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// JobFromContext returns the external ID and submitter carried by a context
+// returned by WithJob. They're empty if they weren't set.
+func JobFromContext(ctx context.Context) (externalID, submitter string) {
+	fields := fieldsFromContext(ctx)
+	externalID, _ = fields[ExternalIDField].(string)
+	submitter, _ = fields[SubmitterField].(string)
+	return externalID, submitter
+}
+
+func fieldsFromContext(ctx context.Context) logrus.Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+// contextHook adds the OTel trace and span IDs and the job fields from the
+// entry's context. Fields that were set explicitly on the entry are left
+// alone.
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	set := func(key string, value interface{}) {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(entry.Context); sc.IsValid() {
+		set(TraceIDField, sc.TraceID().String())
+		set(SpanIDField, sc.SpanID().String())
+	}
+
+	for k, v := range fieldsFromContext(entry.Context) {
+		set(k, v)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextHook(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(contextHook{})
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithJob(ctx, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", "ipcdev@iplantcollaborative.org")
+
+	logger.WithContext(ctx).Info("launched")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line[TraceIDField])
+	assert.Equal(t, "00f067aa0ba902b7", line[SpanIDField])
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", line[ExternalIDField])
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", line[SubmitterField])
+
+	externalID, submitter := JobFromContext(ctx)
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", externalID)
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", submitter)
+
+	buf.Reset()
+	logger.WithContext(context.Background()).Info("no job")
+	line = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.NotContains(t, line, TraceIDField)
+	assert.NotContains(t, line, ExternalIDField)
+
+	externalID, submitter = JobFromContext(context.Background())
+	assert.Empty(t, externalID)
+	assert.Empty(t, submitter)
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Deprecated returns echo middleware for legacy routes that have a successor.
+// Requests are still handled, but each one is logged as a warning and the
+// response carries Deprecation and Link headers pointing callers at the new
+// route. The successor is found by replacing legacyPrefix in the request path
+// with successorPrefix.
+func Deprecated(legacyPrefix, successorPrefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+
+			successor := successorPrefix
+			if rest := strings.Trim(strings.TrimPrefix(request.URL.Path, legacyPrefix), "/"); rest != "" {
+				successor = successorPrefix + "/" + rest
+			}
+
+			Log.WithFields(logrus.Fields{
+				"context":    "deprecated route",
+				"method":     request.Method,
+				"path":       request.URL.Path,
+				"successor":  successor,
+				"remote":     c.RealIP(),
+				"user_agent": request.UserAgent(),
+			}).Warnf("%s %s is deprecated; use %s %s instead", request.Method, request.URL.Path, request.Method, successor)
+
+			c.Response().Header().Set("Deprecation", "true")
+			c.Response().Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+			return next(c)
+		}
+	}
+}
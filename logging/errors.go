@@ -14,17 +14,17 @@ func HTTPErrorHandler(err error, c echo.Context) {
 
 	switch t := err.(type) {
 	case ErrorResponse:
-		code = http.StatusBadRequest
-		body = t
+		code = t.StatusCode()
+		body = t.redacted()
 	case *ErrorResponse:
-		code = http.StatusBadRequest
-		body = t
+		code = t.StatusCode()
+		body = t.redacted()
 	case *echo.HTTPError:
 		echoErr := t
 		code = echoErr.Code
-		body = NewErrorResponse(err)
+		body = NewErrorResponse(err).redacted()
 	default:
-		body = NewErrorResponse(err)
+		body = NewErrorResponse(err).redacted()
 	}
 
 	c.JSON(code, body) //nolint - lack of return value is required by Echo.
@@ -38,6 +38,50 @@ type ErrorResponse struct {
 	Message   string                  `json:"message"`
 	ErrorCode string                  `json:"error_code,omitempty"`
 	Details   *map[string]interface{} `json:"details,omitempty"`
+
+	// HTTPStatus is the status code HTTPErrorHandler responds with. It
+	// defaults to 400 Bad Request when it isn't set.
+	HTTPStatus int `json:"-"`
+}
+
+// Error codes used in the ErrorCode field of an ErrorResponse.
+const (
+	ErrCodeBadRequest    = "ERR_BAD_REQUEST"
+	ErrCodeNotAuthorized = "ERR_NOT_AUTHORIZED"
+	ErrCodeForbidden     = "ERR_FORBIDDEN"
+	ErrCodeNotFound      = "ERR_NOT_FOUND"
+	ErrCodeConflict      = "ERR_CONFLICT"
+	ErrCodeUnavailable   = "ERR_UNAVAILABLE"
+)
+
+// NewStatusErrorResponse constructs an ErrorResponse that HTTPErrorHandler will
+// send with the given HTTP status code.
+func NewStatusErrorResponse(status int, errorCode, message string) ErrorResponse {
+	return ErrorResponse{
+		Message:    message,
+		ErrorCode:  errorCode,
+		HTTPStatus: status,
+	}
+}
+
+// StatusCode returns the HTTP status code for the error, which defaults to 400
+// Bad Request.
+func (e ErrorResponse) StatusCode() int {
+	if e.HTTPStatus == 0 {
+		return http.StatusBadRequest
+	}
+	return e.HTTPStatus
+}
+
+// redacted returns a copy of the ErrorResponse with the message and details
+// scrubbed by the current redactor.
+func (e ErrorResponse) redacted() ErrorResponse {
+	e.Message = RedactString(e.Message)
+	if e.Details != nil {
+		details := RedactValue("", map[string]interface{}(*e.Details)).(map[string]interface{})
+		e.Details = &details
+	}
+	return e
 }
 
 // ErrorBytes returns a byte-array representation of an ErrorResponse.
@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DebugTarget enables debug logging for the entries that have a particular
+// value in a field, such as the entries for one submitter's jobs, until it
+// expires.
+type DebugTarget struct {
+	Field   string    `json:"field"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+type debugKey struct {
+	field string
+	value string
+}
+
+// levels tracks the level set by the operator and the active debug targets.
+// The logger runs at the debug level while any target is active, and
+// debugFilter drops the debug entries that don't belong to a target.
+type levels struct {
+	mu      sync.Mutex
+	base    logrus.Level
+	targets map[debugKey]time.Time
+	timer   *time.Timer
+}
+
+var levelState = &levels{
+	base:    logrus.InfoLevel,
+	targets: map[debugKey]time.Time{},
+}
+
+// ParseLevel converts a level name such as "debug" into a logrus.Level. Only
+// the names accepted by the --log-level flag are allowed.
+func ParseLevel(name string) (logrus.Level, bool) {
+	switch name {
+	case "trace":
+		return logrus.TraceLevel, true
+	case "debug":
+		return logrus.DebugLevel, true
+	case "info":
+		return logrus.InfoLevel, true
+	case "warn":
+		return logrus.WarnLevel, true
+	case "error":
+		return logrus.ErrorLevel, true
+	case "fatal":
+		return logrus.FatalLevel, true
+	case "panic":
+		return logrus.PanicLevel, true
+	default:
+		return 0, false
+	}
+}
+
+// Level returns the level set by the operator, which doesn't include the
+// temporary increase for debug targets.
+func Level() logrus.Level {
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	return levelState.base
+}
+
+// SetLevel changes the log level at runtime.
+func SetLevel(level logrus.Level) {
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	levelState.base = level
+	levelState.apply(time.Now())
+}
+
+// EnableDebug logs debug entries that have the value in the field, typically
+// SubmitterField or ExternalIDField, for the given duration. Enabling a
+// target that's already active replaces its expiration time.
+func EnableDebug(field, value string, d time.Duration) DebugTarget {
+	value = DebugValue(field, value)
+	expires := time.Now().Add(d)
+
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	levelState.targets[debugKey{field, value}] = expires
+	levelState.apply(time.Now())
+
+	return DebugTarget{Field: field, Value: value, Expires: expires}
+}
+
+// DisableDebug removes a debug target. It returns false if the target wasn't
+// active.
+func DisableDebug(field, value string) bool {
+	value = DebugValue(field, value)
+
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	now := time.Now()
+	key := debugKey{field, value}
+	expires, ok := levelState.targets[key]
+	delete(levelState.targets, key)
+	levelState.apply(now)
+	return ok && expires.After(now)
+}
+
+// ReplaceDebugTargets replaces the active debug targets with the given ones,
+// such as the targets shared by every replica. Expired targets are ignored.
+func ReplaceDebugTargets(targets []DebugTarget) {
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	levelState.targets = map[debugKey]time.Time{}
+	for _, t := range targets {
+		levelState.targets[debugKey{t.Field, DebugValue(t.Field, t.Value)}] = t.Expires
+	}
+	levelState.apply(time.Now())
+}
+
+// DebugValue returns the value that a debug target for the field is stored
+// under. Submitters are stored without the DE user domain.
+func DebugValue(field, value string) string {
+	if field == SubmitterField {
+		return ShortUsername(value)
+	}
+	return value
+}
+
+// DebugTargets returns the active debug targets, sorted by field and value.
+func DebugTargets() []DebugTarget {
+	levelState.mu.Lock()
+	defer levelState.mu.Unlock()
+	levelState.apply(time.Now())
+
+	targets := []DebugTarget{}
+	for k, expires := range levelState.targets {
+		targets = append(targets, DebugTarget{Field: k.field, Value: k.value, Expires: expires})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Field != targets[j].Field {
+			return targets[i].Field < targets[j].Field
+		}
+		return targets[i].Value < targets[j].Value
+	})
+	return targets
+}
+
+// apply drops the expired targets, sets the logger level, and schedules the
+// next expiration. The caller must hold the lock.
+func (l *levels) apply(now time.Time) {
+	var next time.Time
+	for k, expires := range l.targets {
+		if !expires.After(now) {
+			delete(l.targets, k)
+			continue
+		}
+		if next.IsZero() || expires.Before(next) {
+			next = expires
+		}
+	}
+
+	level := l.base
+	if len(l.targets) > 0 && level < logrus.DebugLevel {
+		level = logrus.DebugLevel
+	}
+	Log.Logger.SetLevel(level)
+
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if !next.IsZero() {
+		l.timer = time.AfterFunc(next.Sub(now), func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.apply(time.Now())
+		})
+	}
+}
+
+// allows returns true if an entry should be written. Entries above the base
+// level are only written if they match an active debug target. Submitters are
+// compared without the DE user domain, the same way that targets are stored.
+func (l *levels) allows(entry *logrus.Entry) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Level <= l.base {
+		return true
+	}
+
+	now := time.Now()
+	for k, expires := range l.targets {
+		if !expires.After(now) {
+			continue
+		}
+		value := entry.Data[k.field]
+		if s, ok := value.(string); ok && k.field == SubmitterField {
+			value = ShortUsername(s)
+		}
+		if value == k.value {
+			return true
+		}
+	}
+	return false
+}
+
+// debugFilter wraps the configured formatter and suppresses the entries that
+// the logger only let through because a debug target is active. Hooks have
+// already run when the formatter is called, so the entries carry the job
+// fields added by contextHook.
+type debugFilter struct {
+	logrus.Formatter
+}
+
+func (f debugFilter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !levelState.allows(entry) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugTargets(t *testing.T) {
+	var buf bytes.Buffer
+	out, formatter := Log.Logger.Out, Log.Logger.Formatter
+	Log.Logger.SetOutput(&buf)
+	Log.Logger.SetFormatter(debugFilter{&logrus.JSONFormatter{}})
+	Log.Logger.AddHook(contextHook{})
+	defer func() {
+		Log.Logger.SetOutput(out)
+		Log.Logger.SetFormatter(formatter)
+		Log.Logger.ReplaceHooks(logrus.LevelHooks{})
+		DisableDebug(SubmitterField, "ipcdev")
+		SetLevel(logrus.InfoLevel)
+	}()
+
+	SetLevel(logrus.InfoLevel)
+	assert.Equal(t, logrus.InfoLevel, Log.Logger.GetLevel())
+
+	target := EnableDebug(SubmitterField, "ipcdev@iplantcollaborative.org", time.Minute)
+	assert.Equal(t, "ipcdev", target.Value)
+	assert.Equal(t, logrus.DebugLevel, Log.Logger.GetLevel())
+	assert.Equal(t, logrus.InfoLevel, Level())
+	assert.Equal(t, []DebugTarget{target}, DebugTargets())
+
+	traced := WithJob(context.Background(), "07b04ce2-7757-4b21-9e15-0b4c2f44be26", "ipcdev@iplantcollaborative.org")
+	other := WithJob(context.Background(), "2f4c7a7e-0c4b-4f5e-9c1a-0e6b8f3d9a10", "someone")
+
+	Log.WithContext(traced).Debug("traced debug entry")
+	Log.WithField(SubmitterField, "ipcdev@iplantcollaborative.org").Debug("explicit debug entry")
+	Log.WithContext(other).Debug("untraced debug entry")
+	Log.WithContext(other).Info("info entry")
+
+	logged := buf.String()
+	assert.True(t, strings.Contains(logged, "traced debug entry"))
+	assert.True(t, strings.Contains(logged, "explicit debug entry"))
+	assert.False(t, strings.Contains(logged, "untraced debug entry"))
+	assert.True(t, strings.Contains(logged, "info entry"))
+
+	assert.True(t, DisableDebug(SubmitterField, "ipcdev"))
+	assert.False(t, DisableDebug(SubmitterField, "ipcdev"))
+	assert.Equal(t, logrus.InfoLevel, Log.Logger.GetLevel())
+}
+
+func TestDebugTargetsExpire(t *testing.T) {
+	defer SetLevel(logrus.InfoLevel)
+
+	SetLevel(logrus.WarnLevel)
+	EnableDebug(ExternalIDField, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", 10*time.Millisecond)
+	assert.Equal(t, logrus.DebugLevel, Log.Logger.GetLevel())
+
+	assert.Eventually(t, func() bool {
+		return Log.Logger.GetLevel() == logrus.WarnLevel
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, DebugTargets())
+}
+
+func TestParseLevel(t *testing.T) {
+	level, ok := ParseLevel("warn")
+	assert.True(t, ok)
+	assert.Equal(t, logrus.WarnLevel, level)
+
+	_, ok = ParseLevel("loud")
+	assert.False(t, ok)
+}
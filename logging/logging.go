@@ -2,6 +2,7 @@ package logging
 
 import (
 	"log"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -10,32 +11,34 @@ var Log = logrus.WithFields(logrus.Fields{
 	"service": "jex-adapter",
 })
 
-func SetupLogging(configuredLevel string) {
-	var level logrus.Level
+// SetupLogging sets the log level and format. The format is either "text" or
+// "json". Entries logged with a context also get the trace and job fields
+// described in context.go, and every entry that's written is scrubbed by the
+// redactor described in redact.go. The level can be changed later with SetLevel and
+// EnableDebug, which are described in levels.go.
+func SetupLogging(configuredLevel, configuredFormat string) {
+	var formatter logrus.Formatter
 
-	formatter := new(logrus.TextFormatter)
-	formatter.TimestampFormat = "2006-01-02 15:04:05.9999"
-	formatter.FullTimestamp = true
-
-	switch configuredLevel {
-	case "trace":
-		level = logrus.TraceLevel
-	case "debug":
-		level = logrus.DebugLevel
-	case "info":
-		level = logrus.InfoLevel
-	case "warn":
-		level = logrus.WarnLevel
-	case "error":
-		level = logrus.ErrorLevel
-	case "fatal":
-		level = logrus.FatalLevel
-	case "panic":
-		level = logrus.PanicLevel
+	switch configuredFormat {
+	case "", "text":
+		textFormatter := new(logrus.TextFormatter)
+		textFormatter.TimestampFormat = "2006-01-02 15:04:05.9999"
+		textFormatter.FullTimestamp = true
+		formatter = textFormatter
+	case "json":
+		jsonFormatter := new(logrus.JSONFormatter)
+		jsonFormatter.TimestampFormat = time.RFC3339Nano
+		formatter = jsonFormatter
 	default:
+		log.Fatal("incorrect log format")
+	}
+
+	level, ok := ParseLevel(configuredLevel)
+	if !ok {
 		log.Fatal("incorrect log level")
 	}
 
-	Log.Logger.SetLevel(level)
-	Log.Logger.SetFormatter(formatter)
+	Log.Logger.SetFormatter(debugFilter{redactFormatter{formatter}})
+	SetLevel(level)
+	Log.Logger.AddHook(contextHook{})
 }
@@ -0,0 +1,306 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces sensitive values.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedFields are the field names that are redacted when the
+// configuration doesn't list any.
+var DefaultRedactedFields = []string{
+	"password",
+	"secret",
+	"token",
+	"api_key",
+	"authorization",
+	"email",
+}
+
+// DefaultRedactedPatterns are the patterns that are redacted when the
+// configuration doesn't list any. There are none, since a pattern runs on
+// every log message and error; EmailPattern can be listed in
+// redaction.patterns to scrub email addresses.
+var DefaultRedactedPatterns = []string{}
+
+// EmailPattern matches email addresses. DE usernames with a domain suffix
+// match it too, which is why it isn't redacted by default.
+const EmailPattern = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+
+// exemptFields are the keys whose values are never redacted. They hold the
+// submitter's username, which a pattern like EmailPattern would otherwise
+// scrub, and without which the entries and spans of a job can't be found.
+var exemptFields = map[string]bool{
+	SubmitterField:  true,
+	"job.submitter": true,
+}
+
+// Redactor scrubs sensitive values. Values stored under one of the field
+// names are replaced entirely, and substrings that match one of the patterns
+// are replaced wherever they appear.
+type Redactor struct {
+	fields   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fieldWords normalizes a field name so that it can be matched word by word,
+// e.g. "AWS_SECRET_ACCESS_KEY" becomes "_aws_secret_access_key_".
+func fieldWords(name string) string {
+	return "_" + strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_") + "_"
+}
+
+// NewRedactor returns a *Redactor. Field names are compared without regard to
+// case or punctuation, and a name also matches keys that contain it as a
+// whole word, so "secret" matches AWS_SECRET_ACCESS_KEY but not "secretary".
+func NewRedactor(fields, patterns []string) (*Redactor, error) {
+	r := &Redactor{fields: map[string]bool{}}
+	for _, f := range fields {
+		if words := fieldWords(f); words != "__" {
+			r.fields[words] = true
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// NewRedactorFromConfig returns a *Redactor configured by redaction.fields and
+// redaction.patterns, falling back to the defaults for settings that aren't
+// present.
+func NewRedactorFromConfig(cfg *viper.Viper) (*Redactor, error) {
+	fields := DefaultRedactedFields
+	if cfg.IsSet("redaction.fields") {
+		fields = cfg.GetStringSlice("redaction.fields")
+	}
+	patterns := DefaultRedactedPatterns
+	if cfg.IsSet("redaction.patterns") {
+		patterns = cfg.GetStringSlice("redaction.patterns")
+	}
+	return NewRedactor(fields, patterns)
+}
+
+// SensitiveField returns true if values stored under the name are redacted.
+func (r *Redactor) SensitiveField(name string) bool {
+	if name == "" || exemptFields[name] {
+		return false
+	}
+	words := fieldWords(name)
+	for f := range r.fields {
+		if strings.Contains(words, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// String replaces the substrings of s that match a pattern.
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// Value redacts a value stored under the given key. Maps and slices are
+// redacted recursively. Other values, such as structs, are redacted through
+// their JSON representation, falling back to their string representation, and
+// are only replaced when redaction changes them. Values stored under the
+// submitter's keys are left alone.
+func (r *Redactor) Value(key string, value interface{}) interface{} {
+	return r.value(key, value, true)
+}
+
+// Field redacts a log entry field stored under the given key. It's cheaper
+// than Value, since it runs on every entry that's written: values with
+// sensitive keys, strings, errors, and maps and slices of them are redacted
+// the same way, but other values are left alone.
+func (r *Redactor) Field(key string, value interface{}) interface{} {
+	return r.value(key, value, false)
+}
+
+// value does the work for Value and Field. Values of other types are only
+// redacted through their representations if deep is true.
+func (r *Redactor) value(key string, value interface{}, deep bool) interface{} {
+	if exemptFields[key] {
+		return value
+	}
+	if r.SensitiveField(key) {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.String(v)
+	case error:
+		return r.String(v.Error())
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			out[k] = r.value(k, inner, deep)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for k, inner := range v {
+			if r.SensitiveField(k) {
+				out[k] = Redacted
+			} else {
+				out[k] = r.String(inner)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, inner := range v {
+			out[i] = r.value("", inner, deep)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, inner := range v {
+			out[i] = r.String(inner)
+		}
+		return out
+	case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return v
+	default:
+		if !deep {
+			return v
+		}
+		if b, err := json.Marshal(v); err == nil {
+			var generic interface{}
+			if err = json.Unmarshal(b, &generic); err == nil {
+				redacted := r.value(key, generic, deep)
+				if rb, err := json.Marshal(redacted); err == nil && !bytes.Equal(rb, b) {
+					return redacted
+				}
+				return v
+			}
+		}
+		s := fmt.Sprintf("%v", v)
+		if redacted := r.String(s); redacted != s {
+			return redacted
+		}
+		return v
+	}
+}
+
+// Attributes redacts span attributes. Attributes with sensitive keys are
+// replaced, and string values are scrubbed with the patterns, except for the
+// submitter's.
+func (r *Redactor) Attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		switch {
+		case exemptFields[string(kv.Key)]:
+			out[i] = kv
+		case r.SensitiveField(string(kv.Key)):
+			out[i] = attribute.String(string(kv.Key), Redacted)
+		case kv.Value.Type() == attribute.STRING:
+			out[i] = attribute.String(string(kv.Key), r.String(kv.Value.AsString()))
+		case kv.Value.Type() == attribute.STRINGSLICE:
+			out[i] = attribute.StringSlice(string(kv.Key), r.Value("", kv.Value.AsStringSlice()).([]string))
+		default:
+			out[i] = kv
+		}
+	}
+	return out
+}
+
+var redactor atomic.Pointer[Redactor]
+
+func init() {
+	r, err := NewRedactor(DefaultRedactedFields, DefaultRedactedPatterns)
+	if err != nil {
+		panic(err)
+	}
+	redactor.Store(r)
+}
+
+// SetRedactor replaces the redactor used by the log hook, the error handler,
+// and the helper functions below.
+func SetRedactor(r *Redactor) {
+	redactor.Store(r)
+}
+
+// RedactString scrubs a string with the current redactor.
+func RedactString(s string) string {
+	return redactor.Load().String(s)
+}
+
+// RedactValue scrubs a value stored under the given key with the current
+// redactor.
+func RedactValue(key string, value interface{}) interface{} {
+	return redactor.Load().Value(key, value)
+}
+
+// RedactError returns an error with the message scrubbed by the current
+// redactor. It returns nil for a nil error.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{msg: RedactString(err.Error()), err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e redactedError) Error() string { return e.msg }
+
+func (e redactedError) Unwrap() error { return e.err }
+
+// SetSpanAttributes sets attributes on the span after scrubbing them with the
+// current redactor. Use it instead of calling span.SetAttributes directly
+// with values that come from job submissions.
+func SetSpanAttributes(span trace.Span, attrs ...attribute.KeyValue) {
+	span.SetAttributes(redactor.Load().Attributes(attrs)...)
+}
+
+// RecordSpanError records the error on the span and marks the span as failed,
+// after scrubbing the error message with the current redactor.
+func RecordSpanError(span trace.Span, err error) {
+	msg := redactor.Load().String(err.Error())
+	span.RecordError(errors.New(msg))
+	span.SetStatus(codes.Error, msg)
+}
+
+// redactFormatter scrubs the message and fields of each entry before the
+// wrapped formatter writes it. It's wrapped by debugFilter, so the entries
+// that debugFilter drops are never scrubbed.
+type redactFormatter struct {
+	logrus.Formatter
+}
+
+func (f redactFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	r := redactor.Load()
+	entry.Message = r.String(entry.Message)
+	for k, v := range entry.Data {
+		entry.Data[k] = r.Field(k, v)
+	}
+	return f.Formatter.Format(entry)
+}
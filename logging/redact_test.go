@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// withEmailRedaction makes the current redactor scrub email addresses for the
+// rest of the test.
+func withEmailRedaction(t *testing.T) {
+	r, err := NewRedactor(DefaultRedactedFields, []string{EmailPattern})
+	require.NoError(t, err)
+	previous := redactor.Load()
+	SetRedactor(r)
+	t.Cleanup(func() { SetRedactor(previous) })
+}
+
+func TestDefaultRedactorKeepsUsernames(t *testing.T) {
+	r, err := NewRedactor(DefaultRedactedFields, DefaultRedactedPatterns)
+	require.NoError(t, err)
+
+	assert.Equal(t, "launched for ipcdev@iplantcollaborative.org", r.String("launched for ipcdev@iplantcollaborative.org"))
+}
+
+func TestRedactorValue(t *testing.T) {
+	r, err := NewRedactor(DefaultRedactedFields, []string{EmailPattern})
+	require.NoError(t, err)
+
+	assert.True(t, r.SensitiveField("Password"))
+	assert.True(t, r.SensitiveField("AWS_SECRET_ACCESS_KEY"))
+	assert.True(t, r.SensitiveField("x-api-key"))
+	assert.False(t, r.SensitiveField("secretary"))
+	assert.False(t, r.SensitiveField("submitter"))
+
+	assert.Equal(t, "sent to [REDACTED] at noon", r.String("sent to ipcdev@example.org at noon"))
+
+	details := map[string]interface{}{
+		"env":   map[string]string{"GITHUB_TOKEN": "abc123", "food": "banana"},
+		"email": "ipcdev@example.org",
+		"args":  []interface{}{"--notify", "ipcdev@example.org"},
+		"count": 2,
+	}
+	assert.Equal(t, map[string]interface{}{
+		"env":   map[string]string{"GITHUB_TOKEN": Redacted, "food": "banana"},
+		"email": Redacted,
+		"args":  []interface{}{"--notify", Redacted},
+		"count": 2,
+	}, r.Value("", details))
+
+	type fieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+	assert.Equal(t,
+		[]interface{}{map[string]interface{}{"field": "notify", "message": "[REDACTED] isn't allowed"}},
+		r.Value("errors", []fieldError{{Field: "notify", Message: "ipcdev@example.org isn't allowed"}}),
+	)
+	unchanged := []fieldError{{Field: "notify", Message: "required"}}
+	assert.Equal(t, unchanged, r.Value("errors", unchanged))
+
+	// The submitter is left alone, even though it looks like an address.
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", r.Value(SubmitterField, "ipcdev@iplantcollaborative.org"))
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	_, err := NewRedactor(nil, []string{"("})
+	assert.Error(t, err)
+}
+
+func TestRedactorAttributes(t *testing.T) {
+	r, err := NewRedactor(DefaultRedactedFields, []string{EmailPattern})
+	require.NoError(t, err)
+
+	attrs := r.Attributes([]attribute.KeyValue{
+		attribute.String("job.submitter", "ipcdev@example.org"),
+		attribute.String("http.authorization", "Bearer abc123"),
+		attribute.StringSlice("job.args", []string{"-e", "ipcdev@example.org"}),
+		attribute.Int("job.steps", 1),
+	})
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("job.submitter", "ipcdev@example.org"),
+		attribute.String("http.authorization", Redacted),
+		attribute.StringSlice("job.args", []string{"-e", Redacted}),
+		attribute.Int("job.steps", 1),
+	}, attrs)
+}
+
+// marshalCounter counts how many times it's marshaled to JSON.
+type marshalCounter struct {
+	calls *int
+}
+
+func (m marshalCounter) MarshalJSON() ([]byte, error) {
+	*m.calls++
+	return []byte(`"counted"`), nil
+}
+
+func TestRedactFormatter(t *testing.T) {
+	withEmailRedaction(t)
+	var buf bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(redactFormatter{&logrus.JSONFormatter{}})
+
+	var calls int
+	logger.WithFields(logrus.Fields{
+		"password":     "hunter2",
+		"job":          "07b04ce2-7757-4b21-9e15-0b4c2f44be26",
+		"env":          map[string]string{"GITHUB_TOKEN": "abc123"},
+		"value":        marshalCounter{&calls},
+		SubmitterField: "ipcdev@example.org",
+	}).Error(errors.New("unable to notify ipcdev@example.org"))
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, Redacted, line["password"])
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", line["job"])
+	assert.Equal(t, map[string]interface{}{"GITHUB_TOKEN": Redacted}, line["env"])
+	assert.Equal(t, "ipcdev@example.org", line[SubmitterField])
+	assert.Equal(t, "unable to notify [REDACTED]", line["msg"])
+
+	// Values of other types are left alone rather than being redacted
+	// through their JSON, so the only marshaling is the formatter's.
+	assert.Equal(t, 1, calls)
+}
+
+func TestDroppedEntriesAreNotRedacted(t *testing.T) {
+	defer ReplaceDebugTargets(nil)
+	defer SetLevel(logrus.InfoLevel)
+	SetLevel(logrus.InfoLevel)
+	EnableDebug(ExternalIDField, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", time.Minute)
+
+	var (
+		buf    bytes.Buffer
+		called bool
+	)
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.DebugLevel)
+	// The redactor never sees the entry, since the wrapped formatter isn't
+	// called.
+	logger.SetFormatter(debugFilter{redactFormatter{formatterFunc(func(entry *logrus.Entry) ([]byte, error) {
+		called = true
+		return []byte(entry.Message + "\n"), nil
+	})}})
+
+	logger.WithField("password", "hunter2").Debug("dropped")
+	assert.False(t, called)
+	assert.Empty(t, buf.String())
+}
+
+type formatterFunc func(*logrus.Entry) ([]byte, error)
+
+func (f formatterFunc) Format(entry *logrus.Entry) ([]byte, error) { return f(entry) }
+
+func TestHTTPErrorHandlerRedacts(t *testing.T) {
+	withEmailRedaction(t)
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+	details := map[string]interface{}{"token": "abc123", "user": "ipcdev@example.org"}
+	HTTPErrorHandler(ErrorResponse{
+		Message:   "bad submission from ipcdev@example.org",
+		ErrorCode: ErrCodeBadRequest,
+		Details:   &details,
+	}, c)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "bad submission from [REDACTED]", body["message"])
+	assert.Equal(t, map[string]interface{}{"token": Redacted, "user": Redacted}, body["details"])
+	assert.Equal(t, "ipcdev@example.org", details["user"], "the original details shouldn't be modified")
+}
@@ -0,0 +1,38 @@
+package logging
+
+import "strings"
+
+// DefaultUserDomain is the domain that DE usernames are qualified with unless
+// users.domain says otherwise.
+const DefaultUserDomain = "iplantcollaborative.org"
+
+var userSuffix = "@" + DefaultUserDomain
+
+// SetUserDomain sets the domain that DE usernames are qualified with. It's
+// meant to be called once at startup, before anything is logged.
+func SetUserDomain(domain string) {
+	userSuffix = "@" + domain
+}
+
+// ShortUsername returns the username without the DE user domain. Usernames
+// qualified with any other domain are returned unchanged, so that they never
+// match a DE user with the same name.
+func ShortUsername(username string) string {
+	return strings.TrimSuffix(username, userSuffix)
+}
+
+// QualifiedUsername returns the username with the DE user domain, the way
+// that usernames are stored in the DE database. Usernames that are already
+// qualified with a domain are returned unchanged.
+func QualifiedUsername(username string) string {
+	if strings.Contains(username, "@") {
+		return username
+	}
+	return username + userSuffix
+}
+
+// SameUser returns true if both usernames refer to the same user, with or
+// without the DE user domain.
+func SameUser(a, b string) bool {
+	return ShortUsername(a) == ShortUsername(b)
+}
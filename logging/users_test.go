@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernames(t *testing.T) {
+	defer SetUserDomain(DefaultUserDomain)
+
+	assert.Equal(t, "ipcdev", ShortUsername("ipcdev@iplantcollaborative.org"))
+	assert.Equal(t, "ipcdev", ShortUsername("ipcdev"))
+	assert.Equal(t, "ipcdev@example.org", ShortUsername("ipcdev@example.org"))
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", QualifiedUsername("ipcdev"))
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", QualifiedUsername("ipcdev@iplantcollaborative.org"))
+	assert.Equal(t, "ipcdev@example.org", QualifiedUsername("ipcdev@example.org"))
+
+	assert.True(t, SameUser("ipcdev", "ipcdev@iplantcollaborative.org"))
+	assert.False(t, SameUser("alice@a.org", "alice@b.org"))
+	assert.False(t, SameUser("alice", "alice@b.org"))
+
+	SetUserDomain("cyverse.org")
+	assert.True(t, SameUser("ipcdev", "ipcdev@cyverse.org"))
+	assert.False(t, SameUser("ipcdev", "ipcdev@iplantcollaborative.org"))
+}
@@ -9,9 +9,12 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	_ "expvar"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -29,17 +32,32 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
 
 	"github.com/cyverse-de/jex-adapter/adapter"
+	"github.com/cyverse-de/jex-adapter/admin"
+	"github.com/cyverse-de/jex-adapter/audit"
+	"github.com/cyverse-de/jex-adapter/auth"
 	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/deadlines"
+	"github.com/cyverse-de/jex-adapter/grpcapi"
+	"github.com/cyverse-de/jex-adapter/jobstatus"
 	"github.com/cyverse-de/jex-adapter/logging"
 	"github.com/cyverse-de/jex-adapter/millicores"
+	"github.com/cyverse-de/jex-adapter/natsapi"
+	"github.com/cyverse-de/jex-adapter/openapi"
 	"github.com/cyverse-de/jex-adapter/previewer"
+	"github.com/cyverse-de/jex-adapter/routing"
+	"github.com/cyverse-de/jex-adapter/scheduler"
+	"github.com/cyverse-de/jex-adapter/stopqueues"
+	"github.com/cyverse-de/jex-adapter/submissions"
 
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 	"github.com/uptrace/opentelemetry-go-extra/otelsqlx"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 
 	_ "github.com/lib/pq"
@@ -104,6 +122,94 @@ func amqpConnection(cfg *viper.Viper) (*messaging.Client, string) {
 	return amqpclient, exchangeName
 }
 
+// confirmPublisher opens a separate connection to the broker for publishing
+// launch and stop requests with publisher confirms. The exchanges that launches
+// are routed to, other than the default one, have to exist already. It returns
+// nil unless amqp.confirms.enabled is true.
+func confirmPublisher(cfg *viper.Viper, exchanges []string) *adapter.ConfirmPublisher {
+	log := log.WithFields(logrus.Fields{"context": "amqp publisher confirms"})
+
+	if !cfg.GetBool("amqp.confirms.enabled") {
+		log.Info("publisher confirms are disabled")
+		return nil
+	}
+
+	timeout := adapter.DefaultConfirmTimeout
+	if cfg.IsSet("amqp.confirms.timeout") {
+		timeout = cfg.GetDuration("amqp.confirms.timeout")
+	}
+	if timeout <= 0 {
+		log.Fatalf("amqp.confirms.timeout must be greater than zero, not %s", timeout)
+	}
+
+	conn, err := amqp.Dial(cfg.GetString("amqp.uri"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	exchangeType := cfg.GetString("amqp.exchange.type")
+	if exchangeType == "" {
+		exchangeType = "topic"
+	}
+	for _, exchange := range exchanges {
+		if err = channel.ExchangeDeclarePassive(exchange, exchangeType, true, false, false, false, nil); err != nil {
+			log.Fatalf("launches are routed to the %s exchange, which can't be used: %s", exchange, err)
+		}
+	}
+	if err = channel.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	publisher, err := adapter.NewConfirmPublisher(conn, timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The publisher reopens its channel after a channel exception, but it
+	// can't without the connection. The messaging client exits when its own
+	// connection is lost, so do the same.
+	go func() {
+		if err, ok := <-conn.NotifyClose(make(chan *amqp.Error, 1)); ok {
+			log.Fatal(err)
+		}
+	}()
+
+	log.Infof("waiting up to %s for the broker to confirm launch and stop requests", timeout)
+
+	return publisher
+}
+
+// consumeJobUpdates starts listening for job status updates on the exchange
+// that launches are published to. The queue is shared by the replicas.
+func consumeJobUpdates(cfg *viper.Viper, client *messaging.Client, exchangeName string, tracker *jobstatus.Tracker, listener jobstatus.Listener) {
+	log := log.WithFields(logrus.Fields{"context": "job status updates"})
+
+	queue := cfg.GetString("jobstatus.queue")
+	if queue == "" {
+		queue = jobstatus.DefaultQueue
+	}
+
+	exchangeType := cfg.GetString("amqp.exchange.type")
+	if exchangeType == "" {
+		exchangeType = "topic"
+	}
+
+	prefetch := 10
+	if cfg.IsSet("jobstatus.prefetch") {
+		prefetch = cfg.GetInt("jobstatus.prefetch")
+	}
+
+	go client.Listen()
+	client.AddConsumer(exchangeName, exchangeType, queue, messaging.UpdatesKey, tracker.Handler(listener), prefetch)
+
+	log.Infof("consuming job status updates from the %s queue", queue)
+}
+
 //nolint:staticcheck // EncodedConn retirement is a planned follow-up to the protobuf removal
 func natsConnection(natsCluster, creds, tlsca, tlscrt, tlskey string, maxReconnects, reconnectWait int, envPrefix string) (*nats.EncodedConn, error) {
 	nc, err := nats.Connect(
@@ -137,6 +243,41 @@ func natsConnection(natsCluster, creds, tlsca, tlscrt, tlskey string, maxReconne
 
 }
 
+// serverTLSConfig returns the TLS configuration shared by the HTTP and gRPC
+// servers, or nil if http.tls.cert_file isn't set. Client certificates signed
+// by the CA in http.tls.client_ca_file are verified for mTLS authentication.
+func serverTLSConfig(cfg *viper.Viper) (*tls.Config, error) {
+	certFile := cfg.GetString("http.tls.cert_file")
+	if certFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, cfg.GetString("http.tls.key_file"))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if caFile := cfg.GetString("http.tls.client_ca_file"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
 func main() {
 	var (
 		err error
@@ -152,18 +293,25 @@ func main() {
 		reconnectWait     = flag.Int("reconnect-wait", gotelnats.DefaultReconnectWait, "Seconds to wait between reconnection attempts to NATS")
 		envPrefix         = flag.String("env-prefix", cfg.DefaultEnvPrefix, "The prefix for environment variables")
 		addr              = flag.String("addr", ":60000", "The port to listen on for HTTP requests")
+		grpcAddr          = flag.String("grpc-addr", "", "The address to listen on for gRPC requests, e.g. :60001. gRPC is disabled unless this is set.")
 		defaultMillicores = flag.Float64("default-millicores", 4000.0, "The default number of millicores reserved for an analysis.")
 		logLevel          = flag.String("log-level", "info", "One of trace, debug, info, warn, error, fatal, or panic.")
+		logFormat         = flag.String("log-format", "text", "One of text or json.")
 	)
 
 	flag.Parse()
-	logging.SetupLogging(*logLevel)
+	logging.SetupLogging(*logLevel, *logFormat)
 
 	log := log.WithFields(logrus.Fields{"context": "main function"})
 
 	var tracerCtx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
+	// The trace context is forwarded to the job services in the AMQP message
+	// headers even when no exporter is configured, so that traces started
+	// upstream aren't broken here.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
 	shutdown := otelutils.TracerProviderFromEnv(tracerCtx, serviceName, func(e error) { log.Fatal(e) })
 	defer shutdown()
 
@@ -188,6 +336,16 @@ func main() {
 		log.Fatal(err)
 	}
 
+	redactor, err := logging.NewRedactorFromConfig(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.SetRedactor(redactor)
+
+	if c.IsSet("users.domain") {
+		logging.SetUserDomain(c.GetString("users.domain"))
+	}
+
 	envCfg, err := cfg.Init(&cfg.Settings{
 		EnvPrefix:   *envPrefix,
 		ConfigPath:  *cfgPath,
@@ -218,14 +376,142 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	messenger := adapter.NewAMQPMessenger(exchangeName, amqpclient, nc)
+	launchRouter, err := routing.NewFromConfig(c, routing.Route{Exchange: exchangeName, RoutingKey: messaging.LaunchesKey})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		messengerOpts []adapter.MessengerOption
+		exchanges     []string
+	)
+	if launchRouter != nil {
+		exchanges = launchRouter.Exchanges()
+		messengerOpts = append(messengerOpts, adapter.WithRouter(launchRouter))
+	}
+	if publisher := confirmPublisher(c, exchanges); publisher != nil {
+		messengerOpts = append(messengerOpts, adapter.WithPublisher(publisher))
+	} else if len(exchanges) > 0 {
+		log.Fatal("launches can only be routed to other exchanges with publisher confirms enabled")
+	}
+	messenger := adapter.NewAMQPMessenger(exchangeName, amqpclient, nc, messengerOpts...)
+
+	authn, err := auth.NewFromConfig(c)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	p := previewer.New()
-	a := adapter.New(c, detector, messenger)
+	spec, err := openapi.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auditor, err := audit.NewFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auditor != nil {
+		defer func() {
+			_ = auditor.Close()
+		}()
+	}
+
+	subs, err := submissions.NewFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if subs != nil {
+		purgeCtx, stopPurger := context.WithCancel(context.Background())
+		defer stopPurger()
+		go subs.RunPurger(purgeCtx, db.Duration(c, "submissions.purge_interval", submissions.DefaultPurgeInterval))
+	}
+
+	sched, err := scheduler.NewFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dls, err := deadlines.NewFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracker, err := jobstatus.NewFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queues, err := stopqueues.NewFromConfig(context.Background(), c, dbconn, messenger, tracker)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logSettings, err := admin.NewSettingsFromConfig(context.Background(), c, dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p := previewer.New(c)
+	a := adapter.New(c, detector, messenger,
+		adapter.WithDatabase(dbase),
+		adapter.WithAuditor(auditor),
+		adapter.WithSubmissions(subs),
+		adapter.WithScheduler(sched),
+		adapter.WithDeadlines(dls),
+		adapter.WithJobStatus(tracker),
+		adapter.WithStopQueues(queues),
+	)
 
 	go a.Run()
 	defer a.Finish()
 
+	if sched != nil {
+		schedCtx, stopScheduler := context.WithCancel(context.Background())
+		defer stopScheduler()
+		go sched.Run(schedCtx, a, db.Duration(c, "scheduler.interval", scheduler.DefaultInterval))
+	}
+
+	if dls != nil {
+		deadlineCtx, stopDeadlines := context.WithCancel(context.Background())
+		defer stopDeadlines()
+		go dls.Run(deadlineCtx, a, db.Duration(c, "deadlines.interval", deadlines.DefaultInterval))
+	}
+
+	if tracker != nil {
+		consumeJobUpdates(c, amqpclient, exchangeName, tracker, a)
+
+		statePurgeCtx, stopStatePurger := context.WithCancel(context.Background())
+		defer stopStatePurger()
+		go tracker.RunPurger(statePurgeCtx, db.Duration(c, "jobstatus.purge_interval", jobstatus.DefaultPurgeInterval))
+	}
+
+	if queues != nil {
+		queueCtx, stopQueueExpiry := context.WithCancel(context.Background())
+		defer stopQueueExpiry()
+		go queues.Run(queueCtx, db.Duration(c, "stopqueues.interval", stopqueues.DefaultInterval))
+	}
+
+	if logSettings != nil {
+		if err = logSettings.Sync(context.Background()); err != nil {
+			log.Error(err)
+		}
+
+		syncCtx, stopSync := context.WithCancel(context.Background())
+		defer stopSync()
+		go logSettings.Run(syncCtx, db.Duration(c, "admin.log_settings.sync_interval", admin.DefaultSyncInterval))
+	}
+
+	natsService := natsapi.New(nc, envCfg.String("nats.queue_group"), a, p, authn)
+	natsSubs, err := natsService.Subscribe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		for _, sub := range natsSubs {
+			_ = sub.Unsubscribe()
+		}
+	}()
+
 	router := echo.New()
 	router.Use(otelecho.Middleware(serviceName))
 	router.HTTPErrorHandler = logging.HTTPErrorHandler
@@ -243,11 +529,57 @@ func main() {
 		},
 	))
 
+	router.Use(authn.Middleware())
+	router.Use(spec.Validator())
+
+	spec.Routes(router)
 	a.Routes(router)
 
-	previewrouter := router.Group("/arg-preview")
+	previewrouter := router.Group("/arg-preview", logging.Deprecated("/arg-preview", "/v1/preview"))
 	p.Routes(previewrouter)
 
+	v1 := router.Group("/v1")
+	a.V1Routes(v1)
+	p.Routes(v1.Group("/preview"))
+	admin.New(c, admin.WithSettings(logSettings)).Routes(v1.Group("/admin"))
+	if auditor != nil {
+		auditor.Routes(v1.Group("/audit"))
+	}
+	if subs != nil {
+		subs.Routes(v1)
+	}
+
+	tlsConfig, err := serverTLSConfig(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		grpcServer := grpcapi.New(a, p, spec, authn).NewGRPCServer(tlsConfig)
+		defer grpcServer.GracefulStop()
+
+		go func() {
+			log.Infof("starting gRPC server on %s", *grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:      *addr,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+
 	log.Infof("starting server on %s", *addr)
-	log.Fatal(http.ListenAndServe(*addr, router))
+	if server.TLSConfig != nil {
+		log.Fatal(server.ListenAndServeTLS("", ""))
+	}
+	log.Fatal(server.ListenAndServe())
 }
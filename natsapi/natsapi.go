@@ -0,0 +1,365 @@
+// Package natsapi exposes the launch, stop, and preview operations over NATS
+// request/reply subjects. Subscriptions join a queue group so that requests
+// are load balanced across replicas of the service.
+//
+// Failed requests are answered with a svcerror.ServiceError. Its StatusCode
+// is the HTTP status that the same request gets from the HTTP API, and
+// clients should branch on it rather than on ErrorCode. svcerror has no codes
+// for some of those statuses, so ErrorCode is only an approximation: a 409
+// Conflict is reported as BAD_REQUEST and a 503 Service Unavailable as
+// TIMEOUT.
+//
+// Callers are identified by the same authenticators as on the HTTP API. The
+// credentials go in the request header map under the "authorization" and
+// "x-api-key" keys, and the same permissions as on the matching HTTP routes
+// are required. Client certificates aren't available over NATS.
+package natsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cyverse-de/go-mod/gotelnats"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/previewer"
+	"github.com/cyverse-de/model/v6"
+	"github.com/cyverse-de/p/go/header"
+	"github.com/cyverse-de/p/go/svcerror"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "natsapi"})
+
+// The subjects that the service listens on.
+const (
+	LaunchSubject  = "cyverse.jex.launch"
+	StopSubject    = "cyverse.jex.stop"
+	PreviewSubject = "cyverse.jex.preview"
+)
+
+// DefaultQueueGroup is the queue group used when one isn't configured.
+const DefaultQueueGroup = "jex-adapter"
+
+// Launcher launches and stops jobs. It's implemented by *adapter.JEXAdapter.
+type Launcher interface {
+	Launch(ctx context.Context, body []byte) (*model.Job, error)
+	Stop(ctx context.Context, id *auth.Identity, invID string) error
+}
+
+// Previewer renders command-line previews. It's implemented by
+// *previewer.Previewer.
+type Previewer interface {
+	Preview(params model.PreviewableStepParam, format string) (*previewer.Result, error)
+}
+
+// LaunchRequest is the message sent to the launch subject. The Job field
+// contains the same job submission accepted by POST /v1/jobs.
+type LaunchRequest struct {
+	Header *header.Header  `json:"header"`
+	Job    json.RawMessage `json:"job"`
+}
+
+// GetHeader returns the request header.
+func (r *LaunchRequest) GetHeader() *header.Header { return r.Header }
+
+// LaunchResponse is the reply to a LaunchRequest.
+type LaunchResponse struct {
+	Header       *header.Header         `json:"header"`
+	Error        *svcerror.ServiceError `json:"error,omitempty"`
+	InvocationID string                 `json:"invocation_id,omitempty"`
+}
+
+// GetHeader returns the response header.
+func (r *LaunchResponse) GetHeader() *header.Header { return r.Header }
+
+// GetError returns the error included in the response, if any.
+func (r *LaunchResponse) GetError() *svcerror.ServiceError { return r.Error }
+
+// StopRequest is the message sent to the stop subject.
+type StopRequest struct {
+	Header       *header.Header `json:"header"`
+	InvocationID string         `json:"invocation_id"`
+}
+
+// GetHeader returns the request header.
+func (r *StopRequest) GetHeader() *header.Header { return r.Header }
+
+// StopResponse is the reply to a StopRequest.
+type StopResponse struct {
+	Header *header.Header         `json:"header"`
+	Error  *svcerror.ServiceError `json:"error,omitempty"`
+}
+
+// GetHeader returns the response header.
+func (r *StopResponse) GetHeader() *header.Header { return r.Header }
+
+// GetError returns the error included in the response, if any.
+func (r *StopResponse) GetError() *svcerror.ServiceError { return r.Error }
+
+// PreviewRequest is the message sent to the preview subject. Format is one of
+// the formats supported by the previewer package and defaults to
+// previewer.DefaultFormat.
+type PreviewRequest struct {
+	Header *header.Header             `json:"header"`
+	Params model.PreviewableStepParam `json:"params"`
+	Format string                     `json:"format,omitempty"`
+}
+
+// GetHeader returns the request header.
+func (r *PreviewRequest) GetHeader() *header.Header { return r.Header }
+
+// PreviewResponse is the reply to a PreviewRequest.
+type PreviewResponse struct {
+	Header *header.Header         `json:"header"`
+	Error  *svcerror.ServiceError `json:"error,omitempty"`
+	*previewer.Result
+}
+
+// GetHeader returns the response header.
+func (r *PreviewResponse) GetHeader() *header.Header { return r.Header }
+
+// GetError returns the error included in the response, if any.
+func (r *PreviewResponse) GetError() *svcerror.ServiceError { return r.Error }
+
+// Service answers requests sent to the NATS subjects.
+type Service struct {
+	//nolint:staticcheck // EncodedConn retirement is a planned follow-up to the protobuf removal
+	conn       *nats.EncodedConn
+	queueGroup string
+	launcher   Launcher
+	previewer  Previewer
+	auth       *auth.Auth
+}
+
+// New returns a *Service. An empty queueGroup means DefaultQueueGroup.
+//
+//nolint:staticcheck // EncodedConn retirement is a planned follow-up to the protobuf removal
+func New(conn *nats.EncodedConn, queueGroup string, launcher Launcher, p Previewer, authn *auth.Auth) *Service {
+	if queueGroup == "" {
+		queueGroup = DefaultQueueGroup
+	}
+	return &Service{
+		conn:       conn,
+		queueGroup: queueGroup,
+		launcher:   launcher,
+		previewer:  p,
+		auth:       authn,
+	}
+}
+
+// Subscribe adds the queue subscriptions for each of the subjects.
+func (s *Service) Subscribe() ([]*nats.Subscription, error) {
+	handlers := map[string]nats.Handler{
+		LaunchSubject:  s.launchHandler,
+		StopSubject:    s.stopHandler,
+		PreviewSubject: s.previewHandler,
+	}
+
+	var subs []*nats.Subscription
+	for subject, handler := range handlers {
+		sub, err := s.conn.QueueSubscribe(subject, s.queueGroup, handler)
+		if err != nil {
+			for _, prev := range subs {
+				_ = prev.Unsubscribe()
+			}
+			return nil, err
+		}
+		log.Infof("subscribed to %s in queue group %s", subject, s.queueGroup)
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// startSpan extracts the trace context from the request header. The header
+// may be missing from requests sent by clients that don't use gotelnats.
+func startSpan(h *header.Header, subject string) (context.Context, func()) {
+	if h == nil {
+		h = gotelnats.NewHeader()
+	}
+	carrier := gotelnats.PBTextMapCarrier{Header: h}
+	ctx, span := gotelnats.StartSpan(&carrier, subject, gotelnats.Process)
+	return ctx, func() { span.End() }
+}
+
+// authenticate identifies the caller from the credentials in the request
+// header, which are copied into a synthetic *http.Request for the
+// authenticators, and checks that the caller holds one of the permissions.
+// The returned context carries the identity so that it's recorded in the
+// audit log.
+func (s *Service) authenticate(ctx context.Context, h *header.Header, perms ...auth.Permission) (context.Context, *auth.Identity, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	carrier := gotelnats.PBTextMapCarrier{Header: h}
+	if h == nil {
+		carrier.Header = gotelnats.NewHeader()
+	}
+	for _, name := range []string{"authorization", "x-api-key"} {
+		if value := carrier.Get(name); value != "" {
+			r.Header.Set(name, value)
+		}
+	}
+
+	id, err := s.auth.Identify(r)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if err = auth.Check(id, perms...); err != nil {
+		return ctx, nil, err
+	}
+
+	return auth.NewContext(ctx, id), id, nil
+}
+
+func (s *Service) launchHandler(subject, reply string, req *LaunchRequest) {
+	ctx, end := startSpan(req.Header, subject)
+	defer end()
+
+	s.respond(ctx, reply, s.launch(ctx, req))
+}
+
+func (s *Service) stopHandler(subject, reply string, req *StopRequest) {
+	ctx, end := startSpan(req.Header, subject)
+	defer end()
+
+	s.respond(ctx, reply, s.stop(ctx, req))
+}
+
+func (s *Service) previewHandler(subject, reply string, req *PreviewRequest) {
+	ctx, end := startSpan(req.Header, subject)
+	defer end()
+
+	s.respond(ctx, reply, s.preview(ctx, req))
+}
+
+func (s *Service) launch(ctx context.Context, req *LaunchRequest) *LaunchResponse {
+	resp := &LaunchResponse{Header: gotelnats.NewHeader()}
+
+	if len(req.Job) == 0 {
+		resp.Error = serviceError(ctx, logging.ErrorResponse{
+			Message:   "the job field is required",
+			ErrorCode: logging.ErrCodeBadRequest,
+		})
+		return resp
+	}
+
+	ctx, _, err := s.authenticate(ctx, req.Header, auth.PermLaunch)
+	if err != nil {
+		resp.Error = serviceError(ctx, err)
+		return resp
+	}
+
+	job, err := s.launcher.Launch(ctx, req.Job)
+	if err != nil {
+		resp.Error = serviceError(ctx, err)
+		return resp
+	}
+
+	resp.InvocationID = job.InvocationID
+	return resp
+}
+
+// stop checks that the caller may stop the job the same way as the HTTP API
+// does, so callers holding only PermStopOwn can stop just their own jobs.
+func (s *Service) stop(ctx context.Context, req *StopRequest) *StopResponse {
+	resp := &StopResponse{Header: gotelnats.NewHeader()}
+
+	if req.InvocationID == "" {
+		resp.Error = serviceError(ctx, logging.ErrorResponse{
+			Message:   "the invocation_id field is required",
+			ErrorCode: logging.ErrCodeBadRequest,
+		})
+		return resp
+	}
+
+	ctx, id, err := s.authenticate(ctx, req.Header, auth.PermStopAny, auth.PermStopOwn)
+	if err != nil {
+		resp.Error = serviceError(ctx, err)
+		return resp
+	}
+
+	if err = s.launcher.Stop(ctx, id, req.InvocationID); err != nil {
+		resp.Error = serviceError(ctx, err)
+	}
+
+	return resp
+}
+
+func (s *Service) preview(ctx context.Context, req *PreviewRequest) *PreviewResponse {
+	resp := &PreviewResponse{Header: gotelnats.NewHeader()}
+
+	if _, _, err := s.authenticate(ctx, req.Header); err != nil {
+		resp.Error = serviceError(ctx, err)
+		return resp
+	}
+
+	result, err := s.previewer.Preview(req.Params, req.Format)
+	if err != nil {
+		resp.Error = serviceError(ctx, err)
+		return resp
+	}
+
+	resp.Result = result
+	return resp
+}
+
+func (s *Service) respond(ctx context.Context, reply string, resp gotelnats.DEResponse) {
+	if reply == "" {
+		return
+	}
+	if err := gotelnats.PublishResponse(ctx, s.conn, reply, resp); err != nil {
+		log.WithContext(ctx).Error(err)
+	}
+}
+
+// serviceError converts an error returned by the core logic into a
+// *svcerror.ServiceError, keeping the HTTP status code that the REST API
+// would have responded with.
+func serviceError(ctx context.Context, err error) *svcerror.ServiceError {
+	log.WithContext(ctx).Error(err)
+
+	status := http.StatusInternalServerError
+	var errResp logging.ErrorResponse
+	if errors.As(err, &errResp) {
+		status = errResp.StatusCode()
+		err = gotelnats.NewDEServiceError(errorCode(status), logging.RedactString(errResp.Message))
+	} else {
+		err = logging.RedactError(err)
+	}
+
+	return gotelnats.InitServiceError(ctx, err, &gotelnats.ErrorOptions{
+		ErrorCode:  errorCode(status),
+		StatusCode: int32(status),
+	})
+}
+
+// errorCode returns the svcerror code for an HTTP status. svcerror has no
+// codes for conflicts or unavailable services, so 409s, like gRPC's
+// FailedPrecondition, are reported as bad requests that shouldn't be retried
+// as is, and 503s as timeouts that can be. Clients that need to tell them
+// apart use the StatusCode, which keeps the original status.
+func errorCode(status int) svcerror.ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return svcerror.ErrorCode_BAD_REQUEST
+	case http.StatusUnauthorized:
+		return svcerror.ErrorCode_UNAUTHENTICATED
+	case http.StatusForbidden:
+		return svcerror.ErrorCode_FORBIDDEN
+	case http.StatusNotFound:
+		return svcerror.ErrorCode_NOT_FOUND
+	case http.StatusConflict:
+		return svcerror.ErrorCode_BAD_REQUEST
+	case http.StatusServiceUnavailable:
+		return svcerror.ErrorCode_TIMEOUT
+	default:
+		return svcerror.ErrorCode_INTERNAL
+	}
+}
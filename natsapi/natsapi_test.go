@@ -0,0 +1,152 @@
+package natsapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/previewer"
+	"github.com/cyverse-de/model/v6"
+	"github.com/cyverse-de/p/go/header"
+	"github.com/cyverse-de/p/go/svcerror"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLauncher struct {
+	launchErr error
+	stopErr   error
+	stopped   string
+	stopID    *auth.Identity
+}
+
+func (f *fakeLauncher) Launch(_ context.Context, body []byte) (*model.Job, error) {
+	if f.launchErr != nil {
+		return nil, f.launchErr
+	}
+	return &model.Job{InvocationID: "07b04ce2-7757-4b21-9e15-0b4c2f44be26"}, nil
+}
+
+func (f *fakeLauncher) Stop(_ context.Context, id *auth.Identity, invID string) error {
+	f.stopped = invID
+	f.stopID = id
+	return f.stopErr
+}
+
+func TestLaunch(t *testing.T) {
+	s := New(nil, "", &fakeLauncher{}, previewer.New(nil), auth.New(false, nil))
+	assert.Equal(t, DefaultQueueGroup, s.queueGroup)
+
+	resp := s.launch(context.Background(), &LaunchRequest{Job: []byte(`{}`)})
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", resp.InvocationID)
+
+	resp = s.launch(context.Background(), &LaunchRequest{})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_BAD_REQUEST, resp.Error.ErrorCode)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.StatusCode)
+		assert.Equal(t, "the job field is required", resp.Error.Message)
+	}
+
+	s = New(nil, "", &fakeLauncher{launchErr: errors.New("broker unavailable")}, previewer.New(nil), auth.New(false, nil))
+	resp = s.launch(context.Background(), &LaunchRequest{Job: []byte(`{}`)})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_INTERNAL, resp.Error.ErrorCode)
+		assert.Equal(t, int32(http.StatusInternalServerError), resp.Error.StatusCode)
+	}
+}
+
+func TestStop(t *testing.T) {
+	l := &fakeLauncher{}
+	s := New(nil, "", l, previewer.New(nil), auth.New(false, nil))
+
+	resp := s.stop(context.Background(), &StopRequest{InvocationID: "abc"})
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "abc", l.stopped)
+	assert.Equal(t, "anonymous", l.stopID.Subject)
+
+	l.stopErr = logging.NewStatusErrorResponse(http.StatusForbidden, logging.ErrCodeForbidden, "nope")
+	resp = s.stop(context.Background(), &StopRequest{InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_FORBIDDEN, resp.Error.ErrorCode)
+		assert.Equal(t, "nope", resp.Error.Message)
+	}
+
+	l.stopErr = logging.NewStatusErrorResponse(http.StatusConflict, logging.ErrCodeConflict, "already finished")
+	resp = s.stop(context.Background(), &StopRequest{InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_BAD_REQUEST, resp.Error.ErrorCode)
+		assert.Equal(t, int32(http.StatusConflict), resp.Error.StatusCode)
+	}
+
+	l.stopErr = logging.NewStatusErrorResponse(http.StatusServiceUnavailable, logging.ErrCodeUnavailable, "not confirmed")
+	resp = s.stop(context.Background(), &StopRequest{InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_TIMEOUT, resp.Error.ErrorCode)
+		assert.Equal(t, int32(http.StatusServiceUnavailable), resp.Error.StatusCode)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	s := New(nil, "", &fakeLauncher{}, previewer.New(nil), auth.New(false, nil))
+
+	resp := s.preview(context.Background(), &PreviewRequest{
+		Params: model.PreviewableStepParam{
+			{Name: "-b", Value: "two", Order: 2},
+			{Name: "-a", Value: "one", Order: 1},
+		},
+	})
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "-a one -b two", resp.Params)
+}
+
+func credentials(key string) *header.Header {
+	return &header.Header{Map: map[string]*header.Header_Value{"x-api-key": {Value: []string{key}}}}
+}
+
+func TestAuthentication(t *testing.T) {
+	authn := auth.New(true, map[string][]auth.Permission{
+		"launcher": {auth.PermLaunch},
+		"user":     {auth.PermLaunch, auth.PermStopOwn},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "apps", Key: "apps-secret", Roles: []string{"launcher"}},
+		{Name: "ipcdev", Key: "ipcdev-secret", Roles: []string{"user"}},
+	}))
+	l := &fakeLauncher{}
+	s := New(nil, "", l, previewer.New(nil), authn)
+
+	resp := s.stop(context.Background(), &StopRequest{InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_UNAUTHENTICATED, resp.Error.ErrorCode)
+	}
+
+	resp = s.stop(context.Background(), &StopRequest{Header: credentials("wrong"), InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_UNAUTHENTICATED, resp.Error.ErrorCode)
+	}
+
+	resp = s.stop(context.Background(), &StopRequest{Header: credentials("apps-secret"), InvocationID: "abc"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, svcerror.ErrorCode_FORBIDDEN, resp.Error.ErrorCode)
+	}
+	assert.Empty(t, l.stopped)
+
+	// The identity is passed along so that the launcher can check that the
+	// job belongs to the caller.
+	resp = s.stop(context.Background(), &StopRequest{Header: credentials("ipcdev-secret"), InvocationID: "abc"})
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "abc", l.stopped)
+	if assert.NotNil(t, l.stopID) {
+		assert.Equal(t, "ipcdev", l.stopID.Subject)
+	}
+
+	launched := s.launch(context.Background(), &LaunchRequest{Job: []byte(`{}`)})
+	if assert.NotNil(t, launched.Error) {
+		assert.Equal(t, svcerror.ErrorCode_UNAUTHENTICATED, launched.Error.ErrorCode)
+	}
+
+	launched = s.launch(context.Background(), &LaunchRequest{Header: credentials("apps-secret"), Job: []byte(`{}`)})
+	assert.Nil(t, launched.Error)
+}
@@ -0,0 +1,236 @@
+// Package openapi publishes the OpenAPI description of the jex-adapter API and
+// validates incoming requests against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/types"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "openapi"})
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// Spec contains the parsed OpenAPI document for the service.
+type Spec struct {
+	doc    *openapi3.T
+	router routers.Router
+	json   []byte
+}
+
+// New parses and validates the embedded OpenAPI document.
+func New() (*Spec, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(specYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spec{
+		doc:    doc,
+		router: router,
+		json:   docJSON,
+	}, nil
+}
+
+// Routes adds the handler that serves the OpenAPI document.
+func (s *Spec) Routes(router types.Router) {
+	router.GET("/openapi.json", s.Handler)
+}
+
+// Handler responds with the OpenAPI document as JSON.
+func (s *Spec) Handler(c echo.Context) error {
+	return c.JSONBlob(http.StatusOK, s.json)
+}
+
+// findRoute looks up the operation for the request. Trailing slashes are
+// ignored since the routes are registered both with and without them.
+func (s *Spec) findRoute(req *http.Request) (*routers.Route, map[string]string, error) {
+	route, pathParams, err := s.router.FindRoute(req)
+	if err == nil || req.URL.Path == "/" || !strings.HasSuffix(req.URL.Path, "/") {
+		return route, pathParams, err
+	}
+
+	trimmed := req.Clone(req.Context())
+	trimmed.URL.Path = strings.TrimSuffix(req.URL.Path, "/")
+	return s.router.FindRoute(trimmed)
+}
+
+// Validator returns echo middleware that validates request parameters and
+// bodies against the OpenAPI document. It has to be added after the auth
+// middleware. Requests without an identity are passed through unchecked so
+// that the routes reject them before anything about the schema is revealed.
+// Requests for operations that aren't described in the document are passed
+// through unchanged.
+func (s *Spec) Validator() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			if auth.FromContext(c) == nil {
+				return next(c)
+			}
+
+			route, pathParams, err := s.findRoute(req)
+			if err != nil {
+				return next(c)
+			}
+
+			// Older clients don't always set the content type, and every
+			// request body accepted by the service is JSON.
+			if req.ContentLength != 0 && req.Header.Get(echo.HeaderContentType) == "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					MultiError:          true,
+					SkipSettingDefaults: true,
+					AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				},
+			}
+
+			if err = openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+				log.WithContext(req.Context()).WithFields(logrus.Fields{"context": "request validation", "operation": route.Operation.OperationID}).Debug(err)
+				return validationError(err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ValidateBody checks a JSON document against one of the schemas in the
+// components section of the OpenAPI document, such as "JobSubmission". It
+// returns the problems that were found, if any.
+func (s *Spec) ValidateBody(schemaName string, body []byte) ([]FieldError, error) {
+	ref, ok := s.doc.Components.Schemas[schemaName]
+	if !ok || ref.Value == nil {
+		return nil, fmt.Errorf("schema %s is not defined", schemaName)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []FieldError{{Location: "body", Message: err.Error()}}, nil
+	}
+
+	var fieldErrors []FieldError
+	if err := ref.Value.VisitJSON(value, openapi3.MultiErrors()); err != nil {
+		collectFieldErrors(err, &FieldError{Location: "body"}, &fieldErrors)
+	}
+
+	return fieldErrors, nil
+}
+
+// FieldError describes a single problem found while validating a request.
+type FieldError struct {
+	Location string `json:"location"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+func validationError(err error) logging.ErrorResponse {
+	var fieldErrors []FieldError
+	collectFieldErrors(err, &FieldError{}, &fieldErrors)
+	return NewValidationError(fieldErrors)
+}
+
+// NewValidationError returns the 400 ErrorResponse sent for requests that
+// don't match the API description, listing the problems in its details.
+func NewValidationError(fieldErrors []FieldError) logging.ErrorResponse {
+	details := map[string]interface{}{
+		"errors": fieldErrors,
+	}
+
+	return logging.ErrorResponse{
+		Message:   "the request does not match the API description",
+		ErrorCode: logging.ErrCodeBadRequest,
+		Details:   &details,
+	}
+}
+
+// collectFieldErrors flattens the errors returned by the validator into a
+// list of FieldErrors. The current argument carries the location learned from
+// enclosing errors.
+func collectFieldErrors(err error, current *FieldError, out *[]FieldError) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collectFieldErrors(inner, current, out)
+		}
+
+	case *openapi3filter.RequestError:
+		next := *current
+		switch {
+		case e.Parameter != nil:
+			next.Location = e.Parameter.In
+			next.Field = e.Parameter.Name
+		case e.RequestBody != nil:
+			next.Location = "body"
+		}
+		switch e.Err.(type) {
+		case nil:
+			next.Message = e.Reason
+			*out = append(*out, next)
+		case openapi3.MultiError, *openapi3.SchemaError:
+			collectFieldErrors(e.Err, &next, out)
+		default:
+			next.Message = e.Error()
+			*out = append(*out, next)
+		}
+
+	case *openapi3.SchemaError:
+		next := *current
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			field := "/" + strings.Join(pointer, "/")
+			if next.Location == "body" || next.Field == "" {
+				next.Field = field
+			} else {
+				next.Field = next.Field + field
+			}
+		}
+		next.Message = e.Reason
+		if next.Message == "" {
+			next.Message = e.Error()
+		}
+		*out = append(*out, next)
+
+	default:
+		next := *current
+		next.Message = err.Error()
+		*out = append(*out, next)
+	}
+}
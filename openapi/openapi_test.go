@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T) *echo.Echo {
+	return newAuthTestRouter(t, auth.New(false, nil))
+}
+
+func newAuthTestRouter(t *testing.T, a *auth.Auth) *echo.Echo {
+	spec, err := New()
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(a.Middleware())
+	e.Use(spec.Validator())
+	spec.Routes(e)
+
+	ok := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+	e.POST("/", ok, auth.Require(auth.PermLaunch))
+	e.POST("/arg-preview", ok)
+	e.POST("/arg-preview/", ok)
+	e.DELETE("/stop/:invocation_id", ok)
+
+	return e
+}
+
+func TestServesSpec(t *testing.T) {
+	e := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/arg-preview")
+}
+
+func TestValidLaunchPasses(t *testing.T) {
+	e := newTestRouter(t)
+
+	body, err := os.ReadFile("../test/test_submission.json")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestInvalidLaunchIsRejected(t *testing.T) {
+	e := newTestRouter(t)
+
+	body := `{"username": "ipcdev", "steps": [{"component": {"container": {"image": {"name": "alpine"}, "memory_limit": "lots"}}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var resp struct {
+		ErrorCode string `json:"error_code"`
+		Details   struct {
+			Errors []FieldError `json:"errors"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, logging.ErrCodeBadRequest, resp.ErrorCode)
+
+	fields := map[string]bool{}
+	for _, fe := range resp.Details.Errors {
+		assert.Equal(t, "body", fe.Location)
+		fields[fe.Field] = true
+	}
+	assert.True(t, fields["/uuid"], "missing uuid should be reported: %+v", resp.Details.Errors)
+	assert.True(t, fields["/steps/0/component/container/memory_limit"], "bad memory_limit should be reported: %+v", resp.Details.Errors)
+}
+
+func TestNullArraysPass(t *testing.T) {
+	e := newTestRouter(t)
+
+	body := `{"uuid": "07b04ce2-7757-4b21-9e15-0b4c2f44be26", "username": "ipcdev", "user_groups": null, "file-metadata": null,
+		"steps": [{"component": {"container": {"image": {"name": "alpine"}, "container_volumes": null, "ports": null}},
+			"config": {"params": null, "input": null, "output": null}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestUnauthenticatedRequestsAreNotValidated(t *testing.T) {
+	e := newAuthTestRouter(t, auth.New(true, nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"steps": "none"}`))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "steps")
+}
+
+func TestInvalidPreviewIsRejected(t *testing.T) {
+	e := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/arg-preview/", strings.NewReader(`[{"name": "-n", "order": "first"}]`))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/0/order")
+}
@@ -0,0 +1,85 @@
+package previewer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+)
+
+// MaxBatchSize is the largest number of items accepted in a batch preview
+// request.
+const MaxBatchSize = 1000
+
+// BatchItem is the outcome of previewing one item in a batch. Either the
+// embedded Result or Error is set.
+type BatchItem struct {
+	*Result
+	Error *logging.ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchResult contains the outcome for each key in a batch preview request.
+type BatchResult struct {
+	Results map[string]BatchItem `json:"results"`
+}
+
+// PreviewBatch renders each list of parameters in the given format. Problems
+// with an item are reported in that item rather than failing the batch.
+func (p *Previewer) PreviewBatch(items map[string]json.RawMessage, format string) *BatchResult {
+	batch := &BatchResult{Results: make(map[string]BatchItem, len(items))}
+
+	for key, raw := range items {
+		var params model.PreviewableStepParam
+		if err := json.Unmarshal(raw, &params); err != nil {
+			errResp := logging.ErrorResponse{
+				Message:   fmt.Sprintf("unable to parse the parameters: %s", err),
+				ErrorCode: logging.ErrCodeBadRequest,
+			}
+			batch.Results[key] = BatchItem{Error: &errResp}
+			continue
+		}
+
+		result, err := p.Preview(params, format)
+		if err != nil {
+			errResp := logging.NewErrorResponse(err)
+			batch.Results[key] = BatchItem{Error: &errResp}
+			continue
+		}
+
+		batch.Results[key] = BatchItem{Result: result}
+	}
+
+	return batch
+}
+
+// BatchHandler responds with the previews for every item in a request body
+// that maps keys to lists of parameters.
+func (p *Previewer) BatchHandler(c echo.Context) error {
+	bodyBytes, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	var items map[string]json.RawMessage
+	if err = json.Unmarshal(bodyBytes, &items); err != nil {
+		log.Error(err)
+		return logging.ErrorResponse{
+			Message:   fmt.Sprintf("the request body must be an object: %s", err),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+
+	if len(items) > MaxBatchSize {
+		return logging.ErrorResponse{
+			Message:   fmt.Sprintf("a batch may contain at most %d items", MaxBatchSize),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+
+	return c.JSON(http.StatusOK, p.PreviewBatch(items, c.QueryParam("format")))
+}
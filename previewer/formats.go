@@ -0,0 +1,163 @@
+package previewer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFormat is the format used when a preview request doesn't ask for
+// one. It's the space-joined string that the preview endpoint has always
+// returned.
+const DefaultFormat = "string"
+
+// Formatter renders an argument list for a particular backend.
+type Formatter interface {
+	Format(argv []string) (string, error)
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(argv []string) (string, error)
+
+// Format calls f(argv).
+func (f FormatterFunc) Format(argv []string) (string, error) {
+	return f(argv)
+}
+
+var formatters = map[string]Formatter{
+	"json":   FormatterFunc(formatJSON),
+	"shell":  FormatterFunc(formatShell),
+	"condor": FormatterFunc(formatCondor),
+	"k8s":    FormatterFunc(formatK8s),
+}
+
+// RegisterFormatter adds a formatter that can be selected by name. It's meant
+// to be called during initialization and isn't safe for concurrent use.
+func RegisterFormatter(name string, f Formatter) {
+	formatters[name] = f
+}
+
+// Formats returns the names of the supported formats, including the default.
+func Formats() []string {
+	names := []string{DefaultFormat}
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Argv returns the argument list for the parameters in the order that they'll
+// be passed to the tool. Empty names and values are skipped, as they are when
+// the job runs. The parameters aren't modified.
+func Argv(params model.PreviewableStepParam) []string {
+	sorted := make(model.PreviewableStepParam, len(params))
+	copy(sorted, params)
+	sort.Stable(model.ByOrder(sorted))
+
+	argv := []string{}
+	for _, p := range sorted {
+		if name := strings.TrimSpace(p.Name); name != "" {
+			argv = append(argv, name)
+		}
+		if value := strings.TrimSpace(p.Value); value != "" {
+			argv = append(argv, value)
+		}
+	}
+	return argv
+}
+
+// render formats the parameters. An unknown format results in a 400
+// ErrorResponse.
+func render(params model.PreviewableStepParam, format string) (*Result, error) {
+	if format == "" || format == DefaultFormat {
+		sorted := make(model.PreviewableStepParam, len(params))
+		copy(sorted, params)
+		return &Result{Params: sorted.String()}, nil
+	}
+
+	formatter, ok := formatters[format]
+	if !ok {
+		return nil, logging.ErrorResponse{
+			Message:   fmt.Sprintf("unsupported format %q; use one of %s", format, strings.Join(Formats(), ", ")),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+
+	argv := Argv(params)
+	rendered, err := formatter.Format(argv)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Params: rendered,
+		Format: format,
+	}
+	if format == "json" {
+		result.Args = argv
+	}
+	return result, nil
+}
+
+func formatJSON(argv []string) (string, error) {
+	b, err := json.Marshal(argv)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes an argument for a POSIX shell. Arguments that contain
+// only safe characters are left alone, and everything else is wrapped in
+// single quotes, which preserve every character except the single quote.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func formatShell(argv []string) (string, error) {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// condorQuote quotes an argument using the HTCondor "new" arguments syntax.
+// Arguments containing whitespace or single quotes are wrapped in single
+// quotes with embedded single quotes repeated, and double quotes are repeated
+// since the whole string is wrapped in double quotes.
+func condorQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, `"`, `""`)
+	if arg == "" || strings.ContainsAny(arg, " \t\n\r'") {
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	}
+	return arg
+}
+
+func formatCondor(argv []string) (string, error) {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = condorQuote(arg)
+	}
+	return fmt.Sprintf(`arguments = "%s"`, strings.Join(quoted, " ")), nil
+}
+
+func formatK8s(argv []string) (string, error) {
+	b, err := yaml.Marshal(map[string][]string{"args": argv})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
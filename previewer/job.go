@@ -0,0 +1,106 @@
+package previewer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+)
+
+// StepCommand describes the container command that a single job step will
+// run.
+type StepCommand struct {
+	Step             int               `json:"step"`
+	Image            string            `json:"image"`
+	Entrypoint       string            `json:"entrypoint,omitempty"`
+	WorkingDirectory string            `json:"working_directory"`
+	Environment      map[string]string `json:"environment"`
+	Arguments        []string          `json:"arguments"`
+	Command          string            `json:"command"`
+}
+
+// JobResult is the outcome of previewing a whole job submission.
+type JobResult struct {
+	InvocationID string        `json:"invocation_id"`
+	Steps        []StepCommand `json:"steps"`
+}
+
+// ParseJob parses a job submission the same way the launch endpoints do.
+// Parse errors are returned as 400 ErrorResponses.
+func (p *Previewer) ParseJob(body []byte) (*model.Job, error) {
+	job, err := model.NewFromData(p.cfg, body)
+	if err != nil {
+		return nil, logging.ErrorResponse{
+			Message:   fmt.Sprintf("unable to parse the job submission: %s", err),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+	return job, nil
+}
+
+// PreviewJob returns the container command for every step in the job.
+func (p *Previewer) PreviewJob(job *model.Job) *JobResult {
+	result := &JobResult{
+		InvocationID: job.InvocationID,
+		Steps:        make([]StepCommand, 0, len(job.Steps)),
+	}
+
+	for i := range job.Steps {
+		step := &job.Steps[i]
+		container := &step.Component.Container
+
+		image := container.Image.Name
+		if container.Image.Tag != "" {
+			image = fmt.Sprintf("%s:%s", image, container.Image.Tag)
+		}
+
+		env := map[string]string{}
+		for k, v := range step.Environment {
+			env[k] = v
+		}
+
+		args := step.Arguments()
+		if args == nil {
+			args = []string{}
+		}
+
+		command := args
+		if container.EntryPoint != "" {
+			command = append([]string{container.EntryPoint}, args...)
+		}
+
+		result.Steps = append(result.Steps, StepCommand{
+			Step:             i,
+			Image:            image,
+			Entrypoint:       container.EntryPoint,
+			WorkingDirectory: container.WorkingDirectory(),
+			Environment:      env,
+			Arguments:        args,
+			Command:          strings.Join(command, " "),
+		})
+	}
+
+	return result
+}
+
+// JobPreviewHandler responds with the container commands for every step in
+// the job submission in the request body.
+func (p *Previewer) JobPreviewHandler(c echo.Context) error {
+	bodyBytes, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	job, err := p.ParseJob(bodyBytes)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, p.PreviewJob(job))
+}
@@ -0,0 +1,161 @@
+package previewer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cyverse-de/model/v6"
+)
+
+// Warning describes a suspicious parameter found while linting. Index is the
+// position of the parameter in the list that was submitted, not its position
+// after sorting by Order.
+type Warning struct {
+	Index   int    `json:"index"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// Check looks for one kind of problem in a list of parameters.
+type Check interface {
+	Name() string
+	Run(params model.PreviewableStepParam) []Warning
+}
+
+// CheckFunc adapts a function to the Check interface.
+type CheckFunc struct {
+	CheckName string
+	Func      func(params model.PreviewableStepParam) []Warning
+}
+
+// Name returns the name of the check.
+func (c CheckFunc) Name() string {
+	return c.CheckName
+}
+
+// Run calls the check function.
+func (c CheckFunc) Run(params model.PreviewableStepParam) []Warning {
+	return c.Func(params)
+}
+
+var checks = []Check{
+	CheckFunc{"missing", checkMissing},
+	CheckFunc{"duplicate-flag", checkDuplicateFlags},
+	CheckFunc{"empty-value", checkEmptyValues},
+	CheckFunc{"shell-metacharacters", checkShellMetacharacters},
+	CheckFunc{"order", checkOrder},
+}
+
+// RegisterCheck adds a check that's run by Lint. It's meant to be called
+// during initialization and isn't safe for concurrent use.
+func RegisterCheck(c Check) {
+	checks = append(checks, c)
+}
+
+// Lint runs every registered check against the parameters. The check name is
+// filled in on the warnings that a check returns.
+func Lint(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	for _, c := range checks {
+		for _, w := range c.Run(params) {
+			w.Check = c.Name()
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+func checkMissing(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	for i, p := range params {
+		if strings.TrimSpace(p.Name) == "" && strings.TrimSpace(p.Value) == "" {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Message: "the parameter has neither a name nor a value",
+			})
+		}
+	}
+	return warnings
+}
+
+func checkDuplicateFlags(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	seen := map[string]int{}
+	for i, p := range params {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			continue
+		}
+		if first, ok := seen[name]; ok {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Message: fmt.Sprintf("%s is also passed by the parameter at index %d", name, first),
+			})
+			continue
+		}
+		seen[name] = i
+	}
+	return warnings
+}
+
+// checkEmptyValues looks for options without values. Parameters with the
+// Flag type are skipped since they never have values.
+func checkEmptyValues(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	for i, p := range params {
+		name := strings.TrimSpace(p.Name)
+		if !strings.HasPrefix(name, "-") || strings.EqualFold(p.Type, "Flag") {
+			continue
+		}
+		if strings.TrimSpace(p.Value) == "" {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Message: fmt.Sprintf("%s has an empty value", name),
+			})
+		}
+	}
+	return warnings
+}
+
+const shellMetacharacters = "|&;<>()$`\\\"'*?[]#~{}!\n"
+
+func checkShellMetacharacters(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	for i, p := range params {
+		for _, field := range []struct{ label, text string }{{"name", p.Name}, {"value", p.Value}} {
+			if idx := strings.IndexAny(field.text, shellMetacharacters); idx >= 0 {
+				warnings = append(warnings, Warning{
+					Index:   i,
+					Message: fmt.Sprintf("the %s contains the shell metacharacter %q", field.label, field.text[idx]),
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+// checkOrder looks for parameters that share an Order value, which makes
+// their relative positions depend on the sort, and for parameters that were
+// listed out of order.
+func checkOrder(params model.PreviewableStepParam) []Warning {
+	var warnings []Warning
+	seen := map[int]int{}
+	for i, p := range params {
+		if first, ok := seen[p.Order]; ok {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Message: fmt.Sprintf("order %d is also used by the parameter at index %d", p.Order, first),
+			})
+		} else {
+			seen[p.Order] = i
+		}
+
+		if i > 0 && p.Order < params[i-1].Order {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Message: fmt.Sprintf("order %d follows order %d", p.Order, params[i-1].Order),
+			})
+		}
+	}
+	return warnings
+}
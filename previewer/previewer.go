@@ -5,24 +5,36 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/cyverse-de/jex-adapter/auth"
 	"github.com/cyverse-de/jex-adapter/logging"
 	"github.com/cyverse-de/jex-adapter/types"
 	"github.com/cyverse-de/model/v6"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 var log = logging.Log.WithFields(logrus.Fields{"package": "previewer"})
 
-type Previewer struct{}
+type Previewer struct {
+	cfg *viper.Viper
+}
 
-func New() *Previewer {
-	return &Previewer{}
+// New returns a *Previewer. The configuration is used when parsing whole job
+// submissions.
+func New(cfg *viper.Viper) *Previewer {
+	return &Previewer{
+		cfg: cfg,
+	}
 }
 
 func (p *Previewer) Routes(router types.Router) {
-	router.POST("", p.PreviewHandler)
-	router.POST("/", p.PreviewHandler)
+	router.POST("", p.PreviewHandler, auth.Require())
+	router.POST("/", p.PreviewHandler, auth.Require())
+	router.POST("/batch", p.BatchHandler, auth.Require())
+	router.POST("/job", p.JobPreviewHandler, auth.Require())
+	router.POST("/render", p.RenderHandler, auth.Require())
+	router.POST("/staging", p.StagingHandler, auth.Require())
 }
 
 func (p *Previewer) PreviewHandler(c echo.Context) error {
@@ -41,9 +53,33 @@ func (p *Previewer) PreviewHandler(c echo.Context) error {
 		return err
 	}
 
-	output := map[string]string{
-		"params": params.String(),
+	result, err := p.Preview(params, c.QueryParam("format"))
+	if err != nil {
+		log.Error(err)
+		return err
 	}
 
-	return c.JSON(http.StatusOK, output)
+	return c.JSON(http.StatusOK, result)
+}
+
+// Result is the outcome of previewing a list of step parameters. Params
+// contains the arguments rendered in the requested format. Args is only set
+// for the json format.
+type Result struct {
+	Params   string    `json:"params"`
+	Format   string    `json:"format,omitempty"`
+	Args     []string  `json:"args,omitempty"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// Preview renders the command-line arguments for the parameters in the given
+// format, which defaults to DefaultFormat when it's empty, and lints them.
+// It's shared by the HTTP, NATS, and gRPC interfaces.
+func (p *Previewer) Preview(params model.PreviewableStepParam, format string) (*Result, error) {
+	result, err := render(params, format)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = Lint(params)
+	return result, nil
 }
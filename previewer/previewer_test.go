@@ -0,0 +1,218 @@
+package previewer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func getTestConfig() *viper.Viper {
+	cfg := viper.New()
+	cfg.Set("condor.log_path", "/tmp/")
+	cfg.Set("condor.filter_files", "output-last-stderr")
+	cfg.Set("irods.base", "/iplant/home")
+	return cfg
+}
+
+func serve(p *Previewer, target, body string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.POST("/arg-preview", p.PreviewHandler)
+	e.POST("/arg-preview/batch", p.BatchHandler)
+	e.POST("/arg-preview/job", p.JobPreviewHandler)
+	e.POST("/arg-preview/render", p.RenderHandler)
+	e.POST("/arg-preview/staging", p.StagingHandler)
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJobPreview(t *testing.T) {
+	data, err := os.ReadFile("../test/test_submission.json")
+	require.NoError(t, err)
+
+	rec := serve(New(getTestConfig()), "/arg-preview/job", string(data))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result JobResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+
+	assert.Equal(t, "07b04ce2-7757-4b21-9e15-0b4c2f44be26", result.InvocationID)
+	require.Len(t, result.Steps, 1)
+
+	step := result.Steps[0]
+	assert.Equal(t, "gims.iplantcollaborative.org:5000/backwards-compat:latest", step.Image)
+	assert.Equal(t, "/bin/true", step.Entrypoint)
+	assert.Equal(t, "/work", step.WorkingDirectory)
+	assert.Equal(t, map[string]string{"food": "banana", "foo": "bar"}, step.Environment)
+	assert.Equal(t, []string{"/usr/local3/bin/wc_tool-1.00/wc_wrapper.sh", "param1", "Acer-tree.txt", "param0", "wc_out.txt"}, step.Arguments)
+	assert.Equal(t, "/bin/true /usr/local3/bin/wc_tool-1.00/wc_wrapper.sh param1 Acer-tree.txt param0 wc_out.txt", step.Command)
+}
+
+func TestJobPreviewBadSubmission(t *testing.T) {
+	rec := serve(New(getTestConfig()), "/arg-preview/job", "{")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestPreviewFormats(t *testing.T) {
+	params := `[
+		{"name": "--title", "value": "it's a \"test\"", "order": 2},
+		{"name": "-i", "value": "my file.txt", "order": 1},
+		{"name": "-v", "value": "", "order": 3}
+	]`
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"", `-i my file.txt --title it's a "test" -v`},
+		{"string", `-i my file.txt --title it's a "test" -v`},
+		{"json", `["-i","my file.txt","--title","it's a \"test\"","-v"]`},
+		{"shell", `-i 'my file.txt' --title 'it'\''s a "test"' -v`},
+		{"condor", `arguments = "-i 'my file.txt' --title 'it''s a ""test""' -v"`},
+		{"k8s", "args:\n    - -i\n    - my file.txt\n    - --title\n    - it's a \"test\"\n    - -v\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			rec := serve(New(nil), "/arg-preview?format="+tt.format, params)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var result Result
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+			assert.Equal(t, tt.expected, result.Params)
+		})
+	}
+
+	rec := serve(New(nil), "/arg-preview?format=powershell", params)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestLint(t *testing.T) {
+	params := model.PreviewableStepParam{
+		{Name: "-i", Value: "in.txt", Order: 1},
+		{Name: "-o", Value: "", Order: 2},
+		{Name: "-i", Value: "other.txt", Order: 3},
+		{Name: "--verbose", Value: "", Order: 4, Type: "Flag"},
+		{Name: "", Value: "", Order: 6},
+		{Name: "-q", Value: "a; rm -rf /", Order: 5},
+	}
+
+	var found []Warning
+	for _, w := range Lint(params) {
+		found = append(found, Warning{Index: w.Index, Check: w.Check})
+	}
+
+	assert.ElementsMatch(t, []Warning{
+		{Index: 1, Check: "empty-value"},
+		{Index: 2, Check: "duplicate-flag"},
+		{Index: 4, Check: "missing"},
+		{Index: 5, Check: "shell-metacharacters"},
+		{Index: 5, Check: "order"},
+	}, found)
+
+	// Linting must not reorder the submitted parameters.
+	assert.Equal(t, "-i", params[0].Name)
+	assert.Equal(t, "-q", params[5].Name)
+}
+
+func TestRender(t *testing.T) {
+	data, err := os.ReadFile("../test/test_submission.json")
+	require.NoError(t, err)
+
+	cfg := getTestConfig()
+	cfg.Set("condor.path_env_var", "/usr/bin:/bin")
+	cfg.Set("condor.request_disk", 1024)
+	cfg.Set("k8s.namespace", "de-jobs")
+	p := New(cfg)
+
+	rec := serve(p, "/arg-preview/render?target=condor", string(data))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/plain")
+	submit := rec.Body.String()
+	assert.Contains(t, submit, `+IpcUuid = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"`)
+	assert.Contains(t, submit, "accounting_group_user = test_this_is_a_test\n")
+	assert.Contains(t, submit, `environment = "PATH=/usr/bin:/bin"`)
+	assert.Contains(t, submit, "request_disk = 1024\n")
+	assert.True(t, strings.HasSuffix(submit, "queue\n"))
+
+	rec = serve(p, "/arg-preview/render?target=k8s", string(data))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml", rec.Header().Get(echo.HeaderContentType))
+
+	var manifest k8sJob
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &manifest))
+	assert.Equal(t, "Job", manifest.Kind)
+	assert.Equal(t, "de-jobs", manifest.Metadata.Namespace)
+	assert.Equal(t, "job-07b04ce2-7757-4b21-9e15-0b4c2f44be26", manifest.Metadata.Name)
+	require.Len(t, manifest.Spec.Template.Spec.Containers, 1)
+	container := manifest.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "gims.iplantcollaborative.org:5000/backwards-compat:latest", container.Image)
+	assert.Equal(t, []string{"/bin/true"}, container.Command)
+	assert.Equal(t, []k8sEnvVar{{Name: "foo", Value: "bar"}, {Name: "food", Value: "banana"}}, container.Env)
+
+	rec = serve(p, "/arg-preview/render?target=slurm", string(data))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestStagingPlan(t *testing.T) {
+	data, err := os.ReadFile("../test/test_submission.json")
+	require.NoError(t, err)
+
+	rec := serve(New(getTestConfig()), "/arg-preview/staging", string(data))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var plan StagingPlan
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+
+	require.Len(t, plan.Inputs, 1)
+	assert.Equal(t, "/iplant/home/wregglej/Acer-tree.txt", plan.Inputs[0].Source)
+	assert.Equal(t, "Acer-tree.txt", plan.Inputs[0].Destination)
+	assert.Len(t, plan.Outputs, 2)
+	assert.True(t, strings.HasPrefix(plan.OutputDirectory, "/iplant/home/wregglej/analyses/Word_Count_analysis1-2015-09-17-21-42-20.9/"))
+	assert.Equal(t, []string{"output-last-stderr"}, plan.FilterFiles)
+	// Every input and output is retained and the logs are archived, so only
+	// the filter files are excluded.
+	assert.True(t, plan.ArchiveLogs)
+	assert.Equal(t, []string{"output-last-stderr"}, plan.Excluded)
+}
+
+func TestBatchPreview(t *testing.T) {
+	body := `{
+		"step-1": [{"name": "-b", "value": "two", "order": 2}, {"name": "-a", "value": "one", "order": 1}],
+		"step-2": [{"name": "-c", "value": "three four", "order": 1}],
+		"broken": {"name": "-d"}
+	}`
+
+	rec := serve(New(nil), "/arg-preview/batch?format=shell", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var batch BatchResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	require.Len(t, batch.Results, 3)
+
+	assert.Equal(t, "-a one -b two", batch.Results["step-1"].Params)
+	assert.Nil(t, batch.Results["step-1"].Error)
+	assert.Equal(t, "-c 'three four'", batch.Results["step-2"].Params)
+	if assert.NotNil(t, batch.Results["broken"].Error) {
+		assert.Equal(t, logging.ErrCodeBadRequest, batch.Results["broken"].Error.ErrorCode)
+	}
+	assert.Nil(t, batch.Results["broken"].Result)
+
+	rec = serve(New(nil), "/arg-preview/batch", "[]")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,348 @@
+package previewer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Renderer generates a representative backend description of a job, such as
+// an HTCondor submit file.
+type Renderer interface {
+	ContentType() string
+	Render(cfg *viper.Viper, job *model.Job) ([]byte, error)
+}
+
+var renderers = map[string]Renderer{
+	"condor": condorRenderer{},
+	"k8s":    k8sRenderer{},
+}
+
+// RegisterRenderer adds a renderer that can be selected as a render target.
+// It's meant to be called during initialization and isn't safe for concurrent
+// use.
+func RegisterRenderer(target string, r Renderer) {
+	renderers[target] = r
+}
+
+// Targets returns the names of the supported render targets.
+func Targets() []string {
+	var names []string
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render generates the description of the job for the target. An unknown
+// target results in a 400 ErrorResponse.
+func (p *Previewer) Render(job *model.Job, target string) ([]byte, string, error) {
+	r, ok := renderers[target]
+	if !ok {
+		return nil, "", logging.ErrorResponse{
+			Message:   fmt.Sprintf("unsupported target %q; use one of %s", target, strings.Join(Targets(), ", ")),
+			ErrorCode: logging.ErrCodeBadRequest,
+		}
+	}
+
+	out, err := r.Render(p.cfg, job)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, r.ContentType(), nil
+}
+
+// RenderHandler responds with the backend description of the job submission
+// in the request body. The backend is selected with the target query
+// parameter.
+func (p *Previewer) RenderHandler(c echo.Context) error {
+	bodyBytes, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	job, err := p.ParseJob(bodyBytes)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	out, contentType, err := p.Render(job, c.QueryParam("target"))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.Blob(http.StatusOK, contentType, out)
+}
+
+const condorSubmitTemplate = `universe = vanilla
+executable = /usr/local/bin/road-runner
+rank = mips
+requirements = (HAS_HOST_MOUNTS == True)
+arguments = "--config config --job job"
+initialdir = {{.Job.CondorLogDirectory}}
+output = script-output.log
+error = script-error.log
+log = condor.log
+environment = "PATH={{.PathEnv}}"
+accounting_group = de
+accounting_group_user = {{.Job.Submitter}}
+concurrency_limits = _u{{.Job.Submitter}}
+request_cpus = {{.CPUs}}
+{{- if .Memory}}
+request_memory = {{.Memory}}
+{{- end}}
+{{- if .Disk}}
+request_disk = {{.Disk}}
+{{- end}}
++IpcUuid = "{{.Job.InvocationID}}"
++IpcJobId = "generated_script"
++IpcUsername = "{{.Job.Submitter}}"
++IpcUserGroups = {{.Job.FormatUserGroups}}
++IpcExe = "{{.Executable}}"
++IpcExePath = "{{.ExecutablePath}}"
+should_transfer_files = YES
+transfer_input_files = irods-config,iplant.cmd,config,job
+transfer_output_files = logs/de-transfer-trigger.log,logs/logs-stdout-output,logs/logs-stderr-output
+when_to_transfer_output = ON_EXIT_OR_EVICT
+notification = NEVER
+queue
+`
+
+var condorSubmit = template.Must(template.New("condor").Parse(condorSubmitTemplate))
+
+// condorRenderer generates an HTCondor submit description like the ones the
+// old JEX wrote for road-runner. The PATH and the default disk request come
+// from the condor.* configuration.
+type condorRenderer struct{}
+
+func (condorRenderer) ContentType() string {
+	return echo.MIMETextPlainCharsetUTF8
+}
+
+func (condorRenderer) Render(cfg *viper.Viper, job *model.Job) ([]byte, error) {
+	var pathEnv string
+	var defaultDisk int64
+	if cfg != nil {
+		pathEnv = cfg.GetString("condor.path_env_var")
+		defaultDisk = cfg.GetInt64("condor.request_disk")
+	}
+
+	cpus := job.CPURequest()
+	if cpus < 1 {
+		cpus = 1
+	}
+
+	// HTCondor expects memory in MiB and disk in KiB.
+	var memory, disk int64
+	if m := job.MemoryRequest(); m > 0 {
+		memory = (m + (1 << 20) - 1) >> 20
+	}
+	disk = defaultDisk
+	if d := job.DiskRequest(); d > 0 {
+		disk = (d + (1 << 10) - 1) >> 10
+	}
+
+	var executable, executablePath string
+	if len(job.Steps) > 0 {
+		executable = job.Steps[0].Component.Name
+		executablePath = job.Steps[0].Component.Location
+	}
+
+	var buf bytes.Buffer
+	err := condorSubmit.Execute(&buf, map[string]interface{}{
+		"Job":            job,
+		"PathEnv":        pathEnv,
+		"CPUs":           fmt.Sprintf("%g", cpus),
+		"Memory":         memory,
+		"Disk":           disk,
+		"Executable":     executable,
+		"ExecutablePath": executablePath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// The types below describe the subset of a Kubernetes batch/v1 Job that the
+// preview fills in.
+
+type k8sJob struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMetadata `yaml:"metadata"`
+	Spec       k8sJobSpec  `yaml:"spec"`
+}
+
+type k8sMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type k8sJobSpec struct {
+	BackoffLimit          int         `yaml:"backoffLimit"`
+	ActiveDeadlineSeconds int64       `yaml:"activeDeadlineSeconds,omitempty"`
+	Template              k8sTemplate `yaml:"template"`
+}
+
+type k8sTemplate struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     k8sPodSpec  `yaml:"spec"`
+}
+
+type k8sPodSpec struct {
+	RestartPolicy  string         `yaml:"restartPolicy"`
+	InitContainers []k8sContainer `yaml:"initContainers,omitempty"`
+	Containers     []k8sContainer `yaml:"containers"`
+}
+
+type k8sContainer struct {
+	Name       string       `yaml:"name"`
+	Image      string       `yaml:"image"`
+	Command    []string     `yaml:"command,omitempty"`
+	Args       []string     `yaml:"args,omitempty"`
+	WorkingDir string       `yaml:"workingDir,omitempty"`
+	Env        []k8sEnvVar  `yaml:"env,omitempty"`
+	Resources  k8sResources `yaml:"resources,omitempty"`
+}
+
+type k8sEnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type k8sResources struct {
+	Requests map[string]string `yaml:"requests,omitempty"`
+	Limits   map[string]string `yaml:"limits,omitempty"`
+}
+
+// k8sRenderer generates a Kubernetes Job that runs the steps in order. Every
+// step but the last runs as an init container, since init containers run
+// sequentially and must succeed before the next one starts.
+type k8sRenderer struct{}
+
+func (k8sRenderer) ContentType() string {
+	return "application/yaml"
+}
+
+func (k8sRenderer) Render(cfg *viper.Viper, job *model.Job) ([]byte, error) {
+	var namespace string
+	if cfg != nil {
+		namespace = cfg.GetString("k8s.namespace")
+	}
+
+	labels := map[string]string{
+		"external-id": job.InvocationID,
+		"app-id":      job.AppID,
+		"username":    strings.Split(job.Submitter, "@")[0],
+	}
+
+	// The deadline is only set when every step has a time limit.
+	var deadline int64
+	limited := len(job.Steps) > 0
+	var containers []k8sContainer
+	for i := range job.Steps {
+		step := &job.Steps[i]
+		container := &step.Component.Container
+
+		if step.Component.TimeLimit > 0 {
+			deadline += int64(step.Component.TimeLimit)
+		} else {
+			limited = false
+		}
+
+		image := container.Image.Name
+		if container.Image.Tag != "" {
+			image = fmt.Sprintf("%s:%s", image, container.Image.Tag)
+		}
+
+		var env []k8sEnvVar
+		for name, value := range step.Environment {
+			env = append(env, k8sEnvVar{Name: name, Value: value})
+		}
+		sort.Slice(env, func(a, b int) bool { return env[a].Name < env[b].Name })
+
+		c := k8sContainer{
+			Name:       fmt.Sprintf("step-%d", i),
+			Image:      image,
+			Args:       step.Arguments(),
+			WorkingDir: container.WorkingDirectory(),
+			Env:        env,
+			Resources:  k8sResources{Requests: map[string]string{}, Limits: map[string]string{}},
+		}
+		if container.EntryPoint != "" {
+			c.Command = []string{container.EntryPoint}
+		}
+		if container.MinCPUCores > 0 {
+			c.Resources.Requests["cpu"] = fmt.Sprintf("%g", container.MinCPUCores)
+		}
+		if container.MinMemoryLimit > 0 {
+			c.Resources.Requests["memory"] = fmt.Sprintf("%d", container.MinMemoryLimit)
+		}
+		if container.MaxCPUCores > 0 {
+			c.Resources.Limits["cpu"] = fmt.Sprintf("%g", container.MaxCPUCores)
+		}
+		if container.MemoryLimit > 0 {
+			c.Resources.Limits["memory"] = fmt.Sprintf("%d", container.MemoryLimit)
+		}
+
+		containers = append(containers, c)
+	}
+
+	if !limited {
+		deadline = 0
+	}
+
+	podSpec := k8sPodSpec{RestartPolicy: "Never"}
+	if n := len(containers); n > 0 {
+		podSpec.InitContainers = containers[:n-1]
+		podSpec.Containers = containers[n-1:]
+	}
+
+	manifest := k8sJob{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata: k8sMetadata{
+			Name:      fmt.Sprintf("job-%s", job.InvocationID),
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: k8sJobSpec{
+			BackoffLimit:          0,
+			ActiveDeadlineSeconds: deadline,
+			Template: k8sTemplate{
+				Metadata: k8sMetadata{
+					Name:   fmt.Sprintf("job-%s", job.InvocationID),
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
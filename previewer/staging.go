@@ -0,0 +1,117 @@
+package previewer
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/cyverse-de/model/v6"
+	"github.com/labstack/echo/v4"
+)
+
+// StagedInput is a file or folder that will be downloaded from the data store
+// before the job runs.
+type StagedInput struct {
+	Step         int    `json:"step"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Multiplicity string `json:"multiplicity"`
+	Source       string `json:"source"`
+	Destination  string `json:"destination"`
+	UsesTicket   bool   `json:"uses_ticket"`
+	Retain       bool   `json:"retain"`
+}
+
+// StagedOutput is an output declared by a job step.
+type StagedOutput struct {
+	Step         int    `json:"step"`
+	Name         string `json:"name"`
+	Multiplicity string `json:"multiplicity"`
+	Source       string `json:"source"`
+	Retain       bool   `json:"retain"`
+}
+
+// StagingPlan describes the data that will be transferred for a job. Every
+// file in the working directory is uploaded to OutputDirectory except the
+// paths in Excluded, which includes the inputs and outputs that aren't
+// retained, the configured filter files, and the logs when they aren't
+// archived.
+type StagingPlan struct {
+	InvocationID    string         `json:"invocation_id"`
+	Inputs          []StagedInput  `json:"inputs"`
+	Outputs         []StagedOutput `json:"outputs"`
+	OutputDirectory string         `json:"output_directory"`
+	Excluded        []string       `json:"excluded"`
+	FilterFiles     []string       `json:"filter_files"`
+	ArchiveLogs     bool           `json:"archive_logs"`
+}
+
+// StagingPlan returns the staging plan for the job.
+func (p *Previewer) StagingPlan(job *model.Job) *StagingPlan {
+	plan := &StagingPlan{
+		InvocationID:    job.InvocationID,
+		Inputs:          []StagedInput{},
+		Outputs:         []StagedOutput{},
+		OutputDirectory: job.OutputDirectory(),
+		Excluded:        job.ExcludeArguments(),
+		FilterFiles:     job.FilterFiles,
+		ArchiveLogs:     job.ArchiveLogs,
+	}
+	if plan.Excluded == nil {
+		plan.Excluded = []string{}
+	}
+	if plan.FilterFiles == nil {
+		plan.FilterFiles = []string{}
+	}
+
+	for i := range job.Steps {
+		step := &job.Steps[i]
+
+		for j := range step.Config.Inputs {
+			input := &step.Config.Inputs[j]
+			if input.Value == "" {
+				continue
+			}
+			plan.Inputs = append(plan.Inputs, StagedInput{
+				Step:         i,
+				ID:           input.ID,
+				Name:         input.Name,
+				Multiplicity: input.Multiplicity,
+				Source:       input.IRODSPath(),
+				Destination:  input.Source(),
+				UsesTicket:   input.Ticket != "",
+				Retain:       input.Retain,
+			})
+		}
+
+		for j := range step.Config.Outputs {
+			output := &step.Config.Outputs[j]
+			plan.Outputs = append(plan.Outputs, StagedOutput{
+				Step:         i,
+				Name:         output.Name,
+				Multiplicity: output.Multiplicity,
+				Source:       output.Source(),
+				Retain:       output.Retain,
+			})
+		}
+	}
+
+	return plan
+}
+
+// StagingHandler responds with the staging plan for the job submission in
+// the request body.
+func (p *Previewer) StagingHandler(c echo.Context) error {
+	bodyBytes, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	job, err := p.ParseJob(bodyBytes)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, p.StagingPlan(job))
+}
@@ -0,0 +1,192 @@
+// Package routing decides which exchange and routing key a launch request is
+// published to, so that jobs can be sent to executors that run side by side,
+// such as HTCondor for batch jobs and Kubernetes for interactive ones. The
+// rules are read from routing.rules and checked in order, and the first one
+// that matches a job decides where it goes. Jobs that no rule matches go to the
+// default route. Stop requests aren't routed; they're always published to the
+// default exchange, which is where the stop queues are bound.
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+)
+
+// gpuDevicePrefix is the start of the host paths of the devices that are
+// mounted into the containers of jobs that use NVIDIA GPUs.
+const gpuDevicePrefix = "/dev/nvidia"
+
+// Route is where a launch request is published.
+type Route struct {
+	Exchange   string `mapstructure:"exchange"`
+	RoutingKey string `mapstructure:"routing_key"`
+}
+
+// Match lists the conditions that a job has to meet for a rule to apply. A job
+// matches when it meets all of the conditions that are set, and a list matches
+// when any of its values does. A rule with no conditions matches every job.
+type Match struct {
+	Interactive     *bool    `mapstructure:"interactive"`
+	GPU             *bool    `mapstructure:"gpu"`
+	OSG             *bool    `mapstructure:"osg"`
+	AppIDs          []string `mapstructure:"app_ids"`
+	SubmitterGroups []string `mapstructure:"submitter_groups"`
+}
+
+// Rule sends the jobs that match it to its route. An empty exchange or routing
+// key is taken from the default route.
+type Rule struct {
+	Name  string `mapstructure:"name"`
+	Match Match  `mapstructure:"match"`
+	Route `mapstructure:",squash"`
+}
+
+// Router picks the route of each job.
+type Router struct {
+	fallback Route
+	rules    []Rule
+}
+
+// New returns a *Router that checks the rules in order and uses the fallback
+// route for jobs that none of them match.
+func New(fallback Route, rules []Rule) (*Router, error) {
+	r := &Router{fallback: fallback}
+
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Exchange == "" && rule.RoutingKey == "" {
+			return nil, fmt.Errorf("routing rule %s sets neither an exchange nor a routing key", rule.Name)
+		}
+		if rule.Exchange == "" {
+			rule.Exchange = fallback.Exchange
+		}
+		if rule.RoutingKey == "" {
+			rule.RoutingKey = fallback.RoutingKey
+		}
+		r.rules = append(r.rules, rule)
+	}
+
+	return r, nil
+}
+
+// NewFromConfig returns a *Router for the rules in routing.rules. It returns
+// nil if there are no rules or routing.enabled is false.
+func NewFromConfig(cfg *viper.Viper, fallback Route) (*Router, error) {
+	if cfg.IsSet("routing.enabled") && !cfg.GetBool("routing.enabled") {
+		return nil, nil
+	}
+	if !cfg.IsSet("routing.rules") {
+		return nil, nil
+	}
+
+	var rules []Rule
+	if err := cfg.UnmarshalKey("routing.rules", &rules); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	return New(fallback, rules)
+}
+
+// Route returns the route of the job and the name of the rule that chose it,
+// which is empty if the job went to the default route.
+func (r *Router) Route(job *model.Job) (Route, string) {
+	for _, rule := range r.rules {
+		if rule.Match.matches(job) {
+			return rule.Route, rule.Name
+		}
+	}
+	return r.fallback, ""
+}
+
+// Exchanges returns the exchanges that the rules send jobs to, other than the
+// default one.
+func (r *Router) Exchanges() []string {
+	var exchanges []string
+	seen := map[string]bool{r.fallback.Exchange: true}
+	for _, rule := range r.rules {
+		if !seen[rule.Exchange] {
+			seen[rule.Exchange] = true
+			exchanges = append(exchanges, rule.Exchange)
+		}
+	}
+	return exchanges
+}
+
+func (m *Match) matches(job *model.Job) bool {
+	if m.Interactive != nil && *m.Interactive != interactive(job) {
+		return false
+	}
+	if m.GPU != nil && *m.GPU != usesGPU(job) {
+		return false
+	}
+	if m.OSG != nil && *m.OSG != usesOSG(job) {
+		return false
+	}
+	if len(m.AppIDs) > 0 && !contains(m.AppIDs, job.AppID) {
+		return false
+	}
+	if len(m.SubmitterGroups) > 0 && !containsAny(m.SubmitterGroups, job.UserGroups) {
+		return false
+	}
+	return true
+}
+
+// interactive returns true if any of the job's steps runs an interactive
+// (VICE) app.
+func interactive(job *model.Job) bool {
+	for _, step := range job.Steps {
+		if step.Component.IsInteractive {
+			return true
+		}
+	}
+	return false
+}
+
+// usesGPU returns true if any of the job's containers has a GPU mounted.
+func usesGPU(job *model.Job) bool {
+	for _, step := range job.Steps {
+		for _, device := range step.Component.Container.Devices {
+			if strings.HasPrefix(device.HostPath, gpuDevicePrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usesOSG returns true if any of the job's container images has an OSG image
+// path, which means it can run on the Open Science Grid.
+func usesOSG(job *model.Job) bool {
+	for _, image := range job.ContainerImages() {
+		if image.OSGImagePath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, c := range candidates {
+		if contains(values, c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package routing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fallback = Route{Exchange: "de", RoutingKey: "jobs.launches"}
+
+const testRules = `
+routing:
+  rules:
+    - name: vice
+      match:
+        interactive: true
+      routing_key: jobs.launches.vice
+    - name: gpu
+      match:
+        gpu: true
+        interactive: false
+      exchange: gpu
+      routing_key: jobs.launches.gpu
+    - name: osg
+      match:
+        osg: true
+      routing_key: jobs.launches.osg
+    - name: staff
+      match:
+        app_ids: [c7f05682-23c8-4182-b9a2-e09650a5f49b]
+        submitter_groups: [iplant:staff]
+      exchange: staging
+`
+
+func newTestRouter(t *testing.T) *Router {
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(bytes.NewBufferString(testRules)))
+
+	r, err := NewFromConfig(cfg, fallback)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	return r
+}
+
+func step(mutate func(*model.Step)) model.Step {
+	var s model.Step
+	mutate(&s)
+	return s
+}
+
+func TestRoute(t *testing.T) {
+	r := newTestRouter(t)
+
+	interactive := step(func(s *model.Step) { s.Component.IsInteractive = true })
+	gpu := step(func(s *model.Step) {
+		s.Component.Container.Devices = []model.Device{{HostPath: "/dev/nvidia0", ContainerPath: "/dev/nvidia0"}}
+	})
+	osg := step(func(s *model.Step) {
+		s.Component.Container.Image.OSGImagePath = "/cvmfs/singularity.opensciencegrid.org/x"
+	})
+
+	tests := []struct {
+		name  string
+		job   *model.Job
+		route Route
+		rule  string
+	}{
+		{"batch", &model.Job{Steps: []model.Step{{}}}, fallback, ""},
+		{"interactive", &model.Job{Steps: []model.Step{interactive}}, Route{"de", "jobs.launches.vice"}, "vice"},
+		{"interactive gpu", &model.Job{Steps: []model.Step{interactive, gpu}}, Route{"de", "jobs.launches.vice"}, "vice"},
+		{"gpu", &model.Job{Steps: []model.Step{gpu}}, Route{"gpu", "jobs.launches.gpu"}, "gpu"},
+		{"osg", &model.Job{Steps: []model.Step{osg}}, Route{"de", "jobs.launches.osg"}, "osg"},
+		{
+			"staff app",
+			&model.Job{AppID: "c7f05682-23c8-4182-b9a2-e09650a5f49b", UserGroups: []string{"iplant:users", "iplant:staff"}},
+			Route{"staging", "jobs.launches"},
+			"staff",
+		},
+		{
+			"staff app by others",
+			&model.Job{AppID: "c7f05682-23c8-4182-b9a2-e09650a5f49b", UserGroups: []string{"iplant:users"}},
+			fallback,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, rule := r.Route(tt.job)
+			assert.Equal(t, tt.route, route)
+			assert.Equal(t, tt.rule, rule)
+		})
+	}
+}
+
+func TestExchanges(t *testing.T) {
+	assert.Equal(t, []string{"gpu", "staging"}, newTestRouter(t).Exchanges())
+}
+
+func TestNewFromConfigDisabled(t *testing.T) {
+	cfg := viper.New()
+	r, err := NewFromConfig(cfg, fallback)
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(bytes.NewBufferString(testRules)))
+	cfg.Set("routing.enabled", false)
+	r, err = NewFromConfig(cfg, fallback)
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+}
+
+func TestNewRequiresRoute(t *testing.T) {
+	_, err := New(fallback, []Rule{{Name: "empty"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,318 @@
+// Package scheduler holds job submissions that should be launched later and
+// launches them when they come due. Pending launches are kept in the database,
+// and due launches are claimed with SELECT ... FOR UPDATE SKIP LOCKED so that
+// each one is launched by only one of the jex-adapter replicas, and at most
+// once.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "scheduler"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/scheduler"
+
+// Table is the name of the database table that holds the scheduled launches.
+const Table = "jex_adapter_scheduled_launches"
+
+const (
+	// DefaultMaxDelay is how far in the future a launch can be scheduled
+	// unless scheduler.max_delay says otherwise.
+	DefaultMaxDelay = 30 * 24 * time.Hour
+
+	// DefaultInterval is how often due launches are looked for unless
+	// scheduler.interval says otherwise.
+	DefaultInterval = 15 * time.Second
+
+	// batchSize is the largest number of launches claimed at once.
+	batchSize = 20
+)
+
+// The statuses of a scheduled launch. A launch is launching while it's being
+// published; one that stays in that status was interrupted and may or may not
+// have been published.
+const (
+	StatusPending   = "pending"
+	StatusLaunching = "launching"
+	StatusLaunched  = "launched"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+var (
+	// ErrNotFound is returned when there's no scheduled launch for an
+	// invocation ID.
+	ErrNotFound = errors.New("scheduled launch not found")
+
+	// ErrNotPending is returned when a launch can't be canceled because it
+	// has already been launched, has failed, or was canceled.
+	ErrNotPending = errors.New("scheduled launch is not pending")
+
+	// ErrExists is returned when a launch is already scheduled for an
+	// invocation ID.
+	ErrExists = errors.New("a launch is already scheduled for the invocation ID")
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		invocation_id text PRIMARY KEY,
+		created_at timestamp with time zone NOT NULL DEFAULT now(),
+		start_at timestamp with time zone NOT NULL,
+		caller text NOT NULL DEFAULT '',
+		submitter text NOT NULL DEFAULT '',
+		app_id text NOT NULL DEFAULT '',
+		submission bytea NOT NULL,
+		status text NOT NULL DEFAULT 'pending',
+		launched_at timestamp with time zone,
+		error text NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_pending_idx ON ` + Table + ` (start_at) WHERE status = 'pending'`,
+}
+
+// Launch is a scheduled launch.
+type Launch struct {
+	InvocationID string     `json:"invocation_id" db:"invocation_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	StartAt      time.Time  `json:"start_at" db:"start_at"`
+	Caller       string     `json:"caller,omitempty" db:"caller"`
+	Submitter    string     `json:"submitter" db:"submitter"`
+	AppID        string     `json:"app_id" db:"app_id"`
+	Status       string     `json:"status" db:"status"`
+	LaunchedAt   *time.Time `json:"launched_at,omitempty" db:"launched_at"`
+	Error        string     `json:"error,omitempty" db:"error"`
+	Submission   []byte     `json:"-" db:"submission"`
+}
+
+// Launcher launches a job submission. It's implemented by
+// *adapter.JEXAdapter, which runs the quota check again before publishing.
+type Launcher interface {
+	Launch(ctx context.Context, body []byte) (*model.Job, error)
+}
+
+// Store saves scheduled launches and launches them when they're due.
+type Store struct {
+	db       *sqlx.DB
+	maxDelay time.Duration
+}
+
+// New returns a *Store that accepts launches up to maxDelay in the future.
+func New(db *sqlx.DB, maxDelay time.Duration) *Store {
+	return &Store{
+		db:       db,
+		maxDelay: maxDelay,
+	}
+}
+
+// NewFromConfig returns a *Store configured by scheduler.max_delay, after
+// creating the table if it doesn't exist. It returns nil unless
+// scheduler.enabled is true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB) (*Store, error) {
+	if enabled, err := db.Setup(ctx, cfg, "scheduler", conn, schema); !enabled {
+		return nil, err
+	}
+	return New(conn, db.Duration(cfg, "scheduler.max_delay", DefaultMaxDelay)), nil
+}
+
+// MaxDelay returns how far in the future a launch can be scheduled.
+func (s *Store) MaxDelay() time.Duration {
+	return s.maxDelay
+}
+
+// Schedule saves a submission to be launched at startAt. The submitter is the
+// DE username as it appears in the submission. It returns ErrExists if a launch is already scheduled for
+// the job's invocation ID.
+func (s *Store) Schedule(context context.Context, job *model.Job, submitter string, body []byte, caller string, startAt time.Time) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Schedule")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, start_at, caller, submitter, app_id, submission)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	result, err := s.db.ExecContext(ctx, stmt, job.InvocationID, startAt, caller, submitter, job.AppID, body)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+// Get returns the scheduled launch for the invocation ID, or ErrNotFound.
+func (s *Store) Get(context context.Context, invocationID string) (*Launch, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Get")
+	defer span.End()
+
+	const query = `
+		SELECT invocation_id, created_at, start_at, caller, submitter, app_id,
+			status, launched_at, error
+		FROM ` + Table + `
+		WHERE invocation_id = $1
+	`
+
+	var l Launch
+	err := s.db.QueryRowxContext(ctx, query, invocationID).StructScan(&l)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Pending returns the launches that haven't come due yet, soonest first. An
+// empty submitter returns everyone's. Usernames match with or without the DE
+// user domain.
+func (s *Store) Pending(context context.Context, submitter string) ([]Launch, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Pending")
+	defer span.End()
+
+	const query = `
+		SELECT invocation_id, created_at, start_at, caller, submitter, app_id,
+			status, launched_at, error
+		FROM ` + Table + `
+		WHERE status = 'pending'
+		AND ($1 = '' OR submitter IN ($1, $2))
+		ORDER BY start_at
+	`
+
+	var short, qualified string
+	if submitter != "" {
+		short, qualified = logging.ShortUsername(submitter), logging.QualifiedUsername(submitter)
+	}
+
+	launches := []Launch{}
+	if err := s.db.SelectContext(ctx, &launches, query, short, qualified); err != nil {
+		return nil, err
+	}
+	return launches, nil
+}
+
+// Cancel cancels a pending launch. It returns ErrNotFound if there's no
+// scheduled launch for the invocation ID and ErrNotPending if it's no longer
+// pending.
+func (s *Store) Cancel(context context.Context, invocationID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Cancel")
+	defer span.End()
+
+	const stmt = `
+		UPDATE ` + Table + `
+		SET status = 'canceled'
+		WHERE invocation_id = $1
+		AND status = 'pending'
+	`
+
+	result, err := s.db.ExecContext(ctx, stmt, invocationID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	if _, err = s.Get(ctx, invocationID); err != nil {
+		return err
+	}
+	return ErrNotPending
+}
+
+// LaunchDue launches the pending launches whose start time has passed and
+// returns the number that were processed. Due launches are claimed by moving
+// them to the launching status, skipping rows locked by another replica, and
+// the claim is committed before anything is published. A launch is therefore
+// published at most once: if the replica dies part way through, the launch is
+// left in the launching status rather than being published again.
+func (s *Store) LaunchDue(context context.Context, launcher Launcher) (int, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "LaunchDue")
+	defer span.End()
+
+	const claim = `
+		UPDATE ` + Table + `
+		SET status = 'launching'
+		WHERE invocation_id IN (
+			SELECT invocation_id
+			FROM ` + Table + `
+			WHERE status = 'pending'
+			AND start_at <= now()
+			ORDER BY start_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING invocation_id, created_at, start_at, caller, submitter, app_id,
+			status, launched_at, error, submission
+	`
+
+	var due []Launch
+	if err := s.db.SelectContext(ctx, &due, claim, batchSize); err != nil {
+		return 0, err
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].StartAt.Before(due[j].StartAt)
+	})
+
+	const update = `
+		UPDATE ` + Table + `
+		SET status = $2, launched_at = now(), error = $3
+		WHERE invocation_id = $1
+	`
+
+	for _, l := range due {
+		// The launch is recorded as made by whoever scheduled it.
+		ctx := auth.NewContext(ctx, &auth.Identity{Subject: l.Caller, Method: "scheduler"})
+		ctx = logging.WithJob(ctx, l.InvocationID, l.Submitter)
+		log := log.WithContext(ctx).WithFields(logrus.Fields{"context": "scheduled launch"})
+
+		status, message := StatusLaunched, ""
+		if _, launchErr := launcher.Launch(ctx, l.Submission); launchErr != nil {
+			log.Error(launchErr)
+			status, message = StatusFailed, logging.RedactString(launchErr.Error())
+		} else {
+			log.Infof("launched the job scheduled for %s", l.StartAt.Format(time.RFC3339))
+		}
+
+		if _, err := s.db.ExecContext(ctx, update, l.InvocationID, status, message); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(due), nil
+}
+
+// Run calls LaunchDue at every interval until the context is canceled.
+func (s *Store) Run(ctx context.Context, launcher Launcher, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "scheduler"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		// A full batch means that more launches may be due.
+		n, err := s.LaunchDue(ctx, launcher)
+		return n == batchSize, err
+	})
+}
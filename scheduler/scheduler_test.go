@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invocationID = testutil.InvocationID
+
+var columns = []string{"invocation_id", "created_at", "start_at", "caller", "submitter", "app_id", "status", "launched_at", "error"}
+
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return New(conn, time.Hour), mock
+}
+
+type fakeLauncher struct {
+	err      error
+	launched []string
+	callers  []string
+}
+
+func (f *fakeLauncher) Launch(ctx context.Context, body []byte) (*model.Job, error) {
+	f.launched = append(f.launched, string(body))
+	if id := auth.IdentityFromContext(ctx); id != nil {
+		f.callers = append(f.callers, id.Subject)
+	}
+	return nil, f.err
+}
+
+func TestNewFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+
+	// The scheduler is opt-in, so nothing touches the database unless it's
+	// enabled.
+	store, err := NewFromConfig(context.Background(), viper.New(), conn)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	cfg := viper.New()
+	cfg.Set("scheduler.enabled", true)
+	testutil.ExpectSchema(mock, schema)
+
+	store, err = NewFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	assert.Equal(t, DefaultMaxDelay, store.MaxDelay())
+
+	cfg.Set("scheduler.max_delay", "2h")
+	testutil.ExpectSchema(mock, schema)
+
+	store, err = NewFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, store.MaxDelay())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchedule(t *testing.T) {
+	store, mock := newMockStore(t)
+	raw, job := testutil.Submission(t)
+	startAt := time.Now().Add(time.Hour)
+
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, startAt, "apps", "ipcdev@iplantcollaborative.org", job.AppID, raw).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, store.Schedule(context.Background(), job, "ipcdev@iplantcollaborative.org", raw, "apps", startAt))
+
+	mock.ExpectExec("INSERT INTO " + Table).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, store.Schedule(context.Background(), job, "ipcdev@iplantcollaborative.org", raw, "apps", startAt), ErrExists)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancel(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectExec("UPDATE " + Table).WithArgs(invocationID).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, store.Cancel(context.Background(), invocationID))
+
+	mock.ExpectExec("UPDATE " + Table).WithArgs(invocationID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WithArgs(invocationID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(invocationID, time.Now(), time.Now(), "apps", "ipcdev", "app", StatusLaunched, time.Now(), ""))
+	assert.ErrorIs(t, store.Cancel(context.Background(), invocationID), ErrNotPending)
+
+	mock.ExpectExec("UPDATE " + Table).WithArgs("missing").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(columns))
+	assert.ErrorIs(t, store.Cancel(context.Background(), "missing"), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPending(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	// Submitters match with or without the DE user domain, but not with any
+	// other domain.
+	mock.ExpectQuery("SELECT (.+) FROM "+Table).
+		WithArgs("ipcdev", "ipcdev@iplantcollaborative.org").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(invocationID, time.Now(), time.Now().Add(time.Hour), "apps", "ipcdev@iplantcollaborative.org", "app", StatusPending, nil, ""))
+	launches, err := store.Pending(context.Background(), "ipcdev")
+	require.NoError(t, err)
+	assert.Len(t, launches, 1)
+
+	mock.ExpectQuery("SELECT (.+) FROM "+Table).
+		WithArgs("ipcdev@example.org", "ipcdev@example.org").
+		WillReturnRows(sqlmock.NewRows(columns))
+	launches, err = store.Pending(context.Background(), "ipcdev@example.org")
+	require.NoError(t, err)
+	assert.Empty(t, launches)
+
+	mock.ExpectQuery("SELECT (.+) FROM "+Table).WithArgs("", "").WillReturnRows(sqlmock.NewRows(columns))
+	_, err = store.Pending(context.Background(), "")
+	require.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLaunchDue(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	due := sqlmock.NewRows(append(columns, "submission")).
+		AddRow("first", time.Now(), time.Now(), "apps", "ipcdev", "app", StatusLaunching, nil, "", []byte(`{"uuid": "first"}`)).
+		AddRow("second", time.Now(), time.Now(), "apps", "ipcdev", "app", StatusLaunching, nil, "", []byte(`{"uuid": "second"}`))
+
+	// The launches are claimed before they're published, outside of a
+	// transaction, so that they can't be published again.
+	mock.ExpectQuery(regexp.QuoteMeta("SET status = 'launching'")).WithArgs(batchSize).WillReturnRows(due)
+	mock.ExpectExec("UPDATE "+Table).WithArgs("first", StatusFailed, "ipcdev has resource overages").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE "+Table).WithArgs("second", StatusFailed, "ipcdev has resource overages").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	launcher := &fakeLauncher{err: errors.New("ipcdev has resource overages")}
+	n, err := store.LaunchDue(context.Background(), launcher)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{`{"uuid": "first"}`, `{"uuid": "second"}`}, launcher.launched)
+	assert.Equal(t, []string{"apps", "apps"}, launcher.callers)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SET status = 'launching'")).
+		WillReturnRows(sqlmock.NewRows(append(columns, "submission")).
+			AddRow("third", time.Now(), time.Now(), "apps", "ipcdev", "app", StatusLaunching, nil, "", []byte(`{}`)))
+	mock.ExpectExec("UPDATE "+Table).WithArgs("third", StatusLaunched, "").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err = store.LaunchDue(context.Background(), &fakeLauncher{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres runs the store against the schema in a real database. It only
+// runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestPostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("scheduler.enabled", true)
+	store, err := NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	_, job := testutil.Submission(t)
+	body := []byte(`{"uuid": "` + invocationID + `"}`)
+	require.NoError(t, store.Schedule(ctx, job, "ipcdev@iplantcollaborative.org", body, "apps", time.Now().Add(-time.Minute)))
+	assert.ErrorIs(t, store.Schedule(ctx, job, "ipcdev", body, "apps", time.Now()), ErrExists)
+
+	pending, err := store.Pending(ctx, "ipcdev")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", pending[0].Submitter)
+
+	launcher := &fakeLauncher{}
+	n, err := store.LaunchDue(ctx, launcher)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{string(body)}, launcher.launched)
+	assert.Equal(t, []string{"apps"}, launcher.callers)
+
+	l, err := store.Get(ctx, invocationID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusLaunched, l.Status)
+	assert.ErrorIs(t, store.Cancel(ctx, invocationID), ErrNotPending)
+	assert.ErrorIs(t, store.Cancel(ctx, "missing"), ErrNotFound)
+}
@@ -0,0 +1,221 @@
+// Package stopqueues manages the lifecycle of the per-job stop queues that are
+// created when jobs are launched. The broker never removes them by itself
+// unless a consumer attached at some point, so each queue is recorded in the
+// database when it's created and deleted when its job finishes, or once it's
+// older than the TTL if the job's final status update was missed. Queues of
+// jobs that are still running according to the jobstatus package are kept
+// past the TTL.
+package stopqueues
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/jobstatus"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "stopqueues"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/stopqueues"
+
+// Table is the name of the database table that records the stop queues.
+const Table = "jex_adapter_stop_queues"
+
+const (
+	// DefaultTTL is how long a stop queue is kept unless stopqueues.ttl says
+	// otherwise. It's longer than jobs usually run, since deleting the queue
+	// of a running job means that it can no longer be stopped.
+	DefaultTTL = 14 * 24 * time.Hour
+
+	// DefaultInterval is how often expired stop queues are looked for unless
+	// stopqueues.interval says otherwise.
+	DefaultInterval = time.Hour
+
+	// batchSize is the largest number of expired queues claimed at once.
+	batchSize = 100
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		invocation_id text PRIMARY KEY,
+		created_at timestamp with time zone NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_created_at_idx ON ` + Table + ` (created_at)`,
+}
+
+// Deleter deletes the stop queue of a job. It's implemented by
+// *adapter.AMQPMessenger.
+type Deleter interface {
+	DeleteStopQueue(ctx context.Context, invID string) error
+}
+
+// Manager records stop queues and deletes them when they're no longer needed.
+type Manager struct {
+	db      *sqlx.DB
+	deleter Deleter
+	states  *jobstatus.Tracker
+	ttl     time.Duration
+}
+
+// New returns a *Manager that deletes queues through the deleter. Queues older
+// than the TTL are deleted by ExpireOld, unless the tracker says that their
+// jobs haven't finished. The tracker may be nil if job states aren't tracked.
+func New(db *sqlx.DB, deleter Deleter, states *jobstatus.Tracker, ttl time.Duration) *Manager {
+	return &Manager{
+		db:      db,
+		deleter: deleter,
+		states:  states,
+		ttl:     ttl,
+	}
+}
+
+// NewFromConfig returns a *Manager configured by stopqueues.ttl, after creating
+// the table if it doesn't exist. It returns nil unless stopqueues.enabled is
+// true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB, deleter Deleter, states *jobstatus.Tracker) (*Manager, error) {
+	if enabled, err := db.Setup(ctx, cfg, "stopqueues", conn, schema); !enabled {
+		return nil, err
+	}
+	return New(conn, deleter, states, db.Duration(cfg, "stopqueues.ttl", DefaultTTL)), nil
+}
+
+// Created records the stop queue created for a job that was just launched.
+func (m *Manager) Created(context context.Context, invID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Created")
+	defer span.End()
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id)
+		VALUES ($1)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	_, err := m.db.ExecContext(ctx, stmt, invID)
+	return err
+}
+
+// Remove deletes the stop queue of a job that finished. The queue stays
+// recorded if it can't be deleted, so that ExpireOld tries again later.
+func (m *Manager) Remove(context context.Context, invID string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Remove")
+	defer span.End()
+
+	if err := m.deleter.DeleteStopQueue(ctx, invID); err != nil {
+		return err
+	}
+
+	_, err := m.db.ExecContext(ctx, `DELETE FROM `+Table+` WHERE invocation_id = $1`, invID)
+	return err
+}
+
+type claimed struct {
+	InvocationID string    `db:"invocation_id"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// ExpireOld deletes the stop queues that are older than the TTL and returns
+// the number that were deleted. The expired rows are claimed by removing them
+// from the table before the broker is called, so that no transaction is held
+// open while waiting on it. Queues that can't be deleted are recorded again
+// with their original creation time and left for the next run. Queues of jobs
+// that haven't finished are recorded again as if they were just created.
+func (m *Manager) ExpireOld(context context.Context) (int, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "ExpireOld")
+	defer span.End()
+
+	const claim = `
+		DELETE FROM ` + Table + `
+		WHERE invocation_id IN (
+			SELECT invocation_id
+			FROM ` + Table + `
+			WHERE created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING invocation_id, created_at
+	`
+
+	var expired []claimed
+	if err := m.db.SelectContext(ctx, &expired, claim, time.Now().Add(-m.ttl), batchSize); err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for _, q := range expired {
+		ctx := logging.WithJob(ctx, q.InvocationID, "")
+		log := log.WithContext(ctx).WithFields(logrus.Fields{"context": "expiring stop queues"})
+
+		running, err := m.running(ctx, q.InvocationID)
+		if err != nil {
+			log.Error(err)
+			m.restore(ctx, q.InvocationID, q.CreatedAt)
+			continue
+		}
+		if running {
+			log.Debug("keeping the stop queue of a job that hasn't finished")
+			m.restore(ctx, q.InvocationID, time.Now())
+			continue
+		}
+
+		if err = m.deleter.DeleteStopQueue(ctx, q.InvocationID); err != nil {
+			log.Error(err)
+			m.restore(ctx, q.InvocationID, q.CreatedAt)
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// running returns true if the job is tracked and hasn't reached a terminal
+// state. Jobs that aren't tracked are treated as finished.
+func (m *Manager) running(ctx context.Context, invID string) (bool, error) {
+	if m.states == nil {
+		return false, nil
+	}
+
+	js, err := m.states.Get(ctx, invID)
+	if errors.Is(err, jobstatus.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !js.State.Terminal(), nil
+}
+
+// restore records a claimed stop queue again. Failures are only logged, since
+// the queue is still deleted by the sweeper command if it's left behind.
+func (m *Manager) restore(ctx context.Context, invID string, createdAt time.Time) {
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, created_at)
+		VALUES ($1, $2)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	if _, err := m.db.ExecContext(ctx, stmt, invID, createdAt); err != nil {
+		log.WithContext(ctx).WithFields(logrus.Fields{"context": "expiring stop queues"}).Error(err)
+	}
+}
+
+// Run calls ExpireOld at every interval until the context is canceled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "expiring stop queues"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		deleted, err := m.ExpireOld(ctx)
+		if deleted > 0 {
+			log.Infof("deleted %d stop queues older than %s", deleted, m.ttl)
+		}
+		return false, err
+	})
+}
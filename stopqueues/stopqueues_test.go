@@ -0,0 +1,181 @@
+package stopqueues
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/jobstatus"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/cyverse-de/messaging/v9"
+	"github.com/cyverse-de/model/v6"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invocationID = testutil.InvocationID
+
+type fakeDeleter struct {
+	fail    map[string]bool
+	deleted []string
+}
+
+func (f *fakeDeleter) DeleteStopQueue(ctx context.Context, invID string) error {
+	if f.fail[invID] {
+		return errors.New("channel closed")
+	}
+	f.deleted = append(f.deleted, invID)
+	return nil
+}
+
+var claimedColumns = []string{"invocation_id", "created_at"}
+
+func newMockManager(t *testing.T, deleter Deleter, states *jobstatus.Tracker) (*Manager, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return New(conn, deleter, states, time.Hour), mock
+}
+
+func TestNewFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+	deleter := &fakeDeleter{}
+
+	// Queue cleanup is opt-in, so nothing touches the database unless it's
+	// enabled.
+	m, err := NewFromConfig(context.Background(), viper.New(), conn, deleter, nil)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	cfg := viper.New()
+	cfg.Set("stopqueues.enabled", true)
+	cfg.Set("stopqueues.ttl", "2h")
+	testutil.ExpectSchema(mock, schema)
+
+	m, err = NewFromConfig(context.Background(), cfg, conn, deleter, nil)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	// Only the queues older than the TTL are expired.
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).
+		WithArgs(testutil.Within(time.Now().Add(-2*time.Hour)), batchSize).
+		WillReturnRows(sqlmock.NewRows(claimedColumns))
+
+	deleted, err := m.ExpireOld(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatedAndRemove(t *testing.T) {
+	deleter := &fakeDeleter{}
+	m, mock := newMockManager(t, deleter, nil)
+
+	mock.ExpectExec("INSERT INTO " + Table).WithArgs(invocationID).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, m.Created(context.Background(), invocationID))
+
+	mock.ExpectExec("DELETE FROM " + Table).WithArgs(invocationID).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, m.Remove(context.Background(), invocationID))
+	assert.Equal(t, []string{invocationID}, deleter.deleted)
+
+	// The queue stays recorded if it can't be deleted.
+	deleter.fail = map[string]bool{invocationID: true}
+	assert.Error(t, m.Remove(context.Background(), invocationID))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireOld(t *testing.T) {
+	deleter := &fakeDeleter{fail: map[string]bool{"second": true}}
+	m, mock := newMockManager(t, deleter, nil)
+
+	// The claimed rows are gone by the time the broker is called, so there's
+	// no transaction to commit. The queue that couldn't be deleted is recorded
+	// again with its original creation time.
+	created := time.Now().Add(-2 * time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM "+Table)).
+		WithArgs(testutil.Within(time.Now().Add(-time.Hour)), batchSize).
+		WillReturnRows(sqlmock.NewRows(claimedColumns).AddRow("first", created).AddRow("second", created))
+	mock.ExpectExec("INSERT INTO "+Table).WithArgs("second", created).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deleted, err := m.ExpireOld(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"first"}, deleter.deleted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireOldRunningJobs(t *testing.T) {
+	statesConn, statesMock := testutil.MockDB(t)
+	states := jobstatus.New(statesConn, time.Hour)
+
+	deleter := &fakeDeleter{}
+	m, mock := newMockManager(t, deleter, states)
+
+	created := time.Now().Add(-2 * time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM " + Table)).
+		WillReturnRows(sqlmock.NewRows(claimedColumns).
+			AddRow("running", created).
+			AddRow("completed", created).
+			AddRow("untracked", created))
+
+	stateColumns := []string{"invocation_id", "state", "submitter", "message", "stop_requested", "created_at", "updated_at"}
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs("running").
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("running", jobstatus.Running, "ipcdev", "", false, created, created))
+
+	// The queue of the running job is kept for another TTL.
+	mock.ExpectExec("INSERT INTO "+Table).WithArgs("running", testutil.Within(time.Now())).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs("completed").
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("completed", jobstatus.Completed, "ipcdev", "", false, created, created))
+	statesMock.ExpectQuery("SELECT (.+) FROM " + jobstatus.Table).WithArgs("untracked").
+		WillReturnRows(sqlmock.NewRows(stateColumns))
+
+	deleted, err := m.ExpireOld(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{"completed", "untracked"}, deleter.deleted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, statesMock.ExpectationsWereMet())
+}
+
+// TestPostgres runs the manager against the schema in a real database. It only
+// runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestPostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("jobstatus.enabled", true)
+	cfg.Set("stopqueues.enabled", true)
+	states, err := jobstatus.NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	deleter := &fakeDeleter{}
+	m, err := NewFromConfig(ctx, cfg, conn, deleter, states)
+	require.NoError(t, err)
+
+	for _, id := range []string{"running", "finished", "recent", "removed"} {
+		require.NoError(t, m.Created(ctx, id))
+	}
+	require.NoError(t, m.Remove(ctx, "removed"))
+	_, err = conn.Exec(`UPDATE ` + Table + ` SET created_at = now() - interval '30 days' WHERE invocation_id IN ('running', 'finished')`)
+	require.NoError(t, err)
+
+	_, _, err = states.Apply(ctx, &messaging.UpdateMessage{Job: &model.Job{InvocationID: "running"}, State: messaging.RunningState})
+	require.NoError(t, err)
+
+	deleted, err := m.ExpireOld(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"removed", "finished"}, deleter.deleted)
+
+	// The queue of the running job is kept for another TTL.
+	var remaining []string
+	require.NoError(t, conn.Select(&remaining, `SELECT invocation_id FROM `+Table+` ORDER BY invocation_id`))
+	assert.Equal(t, []string{"recent", "running"}, remaining)
+}
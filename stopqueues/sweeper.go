@@ -0,0 +1,167 @@
+package stopqueues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cyverse-de/messaging/v9"
+)
+
+// The stop queue names are built by messaging.StopQueueName, which wraps the
+// invocation ID in this prefix and suffix.
+var stopQueuePrefix, stopQueueSuffix = func() (string, string) {
+	const marker = "\x00"
+	parts := strings.SplitN(messaging.StopQueueName(marker), marker, 2)
+	return parts[0], parts[1]
+}()
+
+// InvocationID returns the invocation ID of the job that a stop queue belongs
+// to, and false if the queue isn't a stop queue.
+func InvocationID(queue string) (string, bool) {
+	if !strings.HasPrefix(queue, stopQueuePrefix) || !strings.HasSuffix(queue, stopQueueSuffix) {
+		return "", false
+	}
+	invID := strings.TrimSuffix(strings.TrimPrefix(queue, stopQueuePrefix), stopQueueSuffix)
+	return invID, invID != ""
+}
+
+// Queue is the part of the RabbitMQ management API's description of a queue
+// that the sweeper uses.
+type Queue struct {
+	Name      string `json:"name"`
+	Consumers int    `json:"consumers"`
+	Messages  int    `json:"messages"`
+	IdleSince string `json:"idle_since"`
+}
+
+// idleSince parses the time the queue was last used. Older versions of the
+// management API leave out the time zone, in which case it's UTC. The second
+// return value is false if the queue isn't idle.
+func (q *Queue) idleSince() (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02T15:04:05.000-07:00", time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, q.IdleSince); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Sweeper finds and deletes stale stop queues through the RabbitMQ management
+// API, since AMQP itself has no way to list queues. It's used by the
+// stop-queue-sweeper command to clean up the queues that were left behind
+// before the Manager existed.
+type Sweeper struct {
+	baseURL  string
+	vhost    string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewSweeper returns a *Sweeper for the management API at managementURL. The
+// credentials and the virtual host are taken from the AMQP URI.
+func NewSweeper(managementURL, amqpURI string) (*Sweeper, error) {
+	if managementURL == "" {
+		return nil, fmt.Errorf("the management API URL is required")
+	}
+
+	u, err := url.Parse(amqpURI)
+	if err != nil {
+		return nil, err
+	}
+
+	vhost := "/"
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		vhost = p
+	}
+
+	password, _ := u.User.Password()
+
+	return &Sweeper{
+		baseURL:  strings.TrimSuffix(managementURL, "/"),
+		vhost:    vhost,
+		username: u.User.Username(),
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Sweeper) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.username, s.password)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Stale returns the stop queues that have no consumers and have been idle for
+// at least idleFor. A job that's still waiting to start has no consumer on its
+// stop queue either, so idleFor should be longer than jobs wait in the queue.
+func (s *Sweeper) Stale(ctx context.Context, idleFor time.Duration) ([]Queue, error) {
+	var queues []Queue
+	path := "/api/queues/" + url.PathEscape(s.vhost) + "?columns=name,consumers,messages,idle_since"
+	if err := s.do(ctx, http.MethodGet, path, &queues); err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-idleFor)
+
+	var stale []Queue
+	for _, q := range queues {
+		if _, ok := InvocationID(q.Name); !ok || q.Consumers > 0 {
+			continue
+		}
+		if idle, ok := q.idleSince(); ok && idle.Before(cutoff) {
+			stale = append(stale, q)
+		}
+	}
+	return stale, nil
+}
+
+// Delete deletes a queue unless a consumer has attached to it since it was
+// found.
+func (s *Sweeper) Delete(ctx context.Context, name string) error {
+	path := "/api/queues/" + url.PathEscape(s.vhost) + "/" + url.PathEscape(name) + "?if-unused=true"
+	return s.do(ctx, http.MethodDelete, path, nil)
+}
+
+// Sweep deletes the stale stop queues and returns their names. With dryRun set
+// it only returns the names.
+func (s *Sweeper) Sweep(ctx context.Context, idleFor time.Duration, dryRun bool) ([]string, error) {
+	stale, err := s.Stale(ctx, idleFor)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, q := range stale {
+		if !dryRun {
+			if err = s.Delete(ctx, q.Name); err != nil {
+				log.WithContext(ctx).Errorf("unable to delete %s: %s", q.Name, err)
+				continue
+			}
+		}
+		deleted = append(deleted, q.Name)
+	}
+	return deleted, nil
+}
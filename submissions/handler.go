@@ -0,0 +1,49 @@
+package submissions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/types"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Routes adds the handler that returns stored submissions. Submissions can
+// contain secrets, so it requires the admin permission, which callers don't
+// have when authentication is disabled.
+func (s *Store) Routes(router types.Router) types.Router {
+	log := log.WithFields(logrus.Fields{"context": "adding submission routes"})
+
+	router.GET("/jobs/:invocation_id/submission", s.SubmissionHandler, auth.Require(auth.PermAdmin))
+	log.Info("added handler for GET /v1/jobs/:invocation_id/submission")
+
+	return router
+}
+
+// SubmissionHandler responds with the submission stored for the job. The
+// stored documents are returned as they are, without being decoded, so that
+// key order and large numbers are kept. The route is only open to admins, so
+// the credentials that submissions can carry aren't redacted.
+func (s *Store) SubmissionHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	invID := c.Param("invocation_id")
+
+	sub, err := s.Get(ctx, invID)
+	if errors.Is(err, ErrNotFound) {
+		return logging.NewStatusErrorResponse(
+			http.StatusNotFound,
+			logging.ErrCodeNotFound,
+			fmt.Sprintf("no submission is stored for job %s", invID),
+		)
+	}
+	if err != nil {
+		log.WithContext(logging.WithJob(ctx, invID, "")).Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sub)
+}
@@ -0,0 +1,216 @@
+// Package submissions keeps the job submissions received by the jex-adapter so
+// that failed analyses can be reproduced and relaunched. Both the submitted
+// JSON and the normalized job are stored gzip-compressed in the database,
+// keyed by invocation ID, and removed once they're older than the retention
+// period.
+package submissions
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/model/v6"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+var log = logging.Log.WithFields(logrus.Fields{"package": "submissions"})
+
+const otelName = "github.com/cyverse-de/jex-adapter/submissions"
+
+// Table is the name of the database table that holds the submissions.
+const Table = "jex_adapter_submissions"
+
+// DefaultRetention is how long submissions are kept unless
+// submissions.retention says otherwise.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// DefaultPurgeInterval is how often expired submissions are removed unless
+// submissions.purge_interval says otherwise.
+const DefaultPurgeInterval = time.Hour
+
+// ErrNotFound is returned when there's no stored submission for an invocation
+// ID, or when it has expired.
+var ErrNotFound = errors.New("submission not found")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + Table + ` (
+		invocation_id text PRIMARY KEY,
+		created_at timestamp with time zone NOT NULL DEFAULT now(),
+		submitter text NOT NULL DEFAULT '',
+		app_id text NOT NULL DEFAULT '',
+		relaunched_from text NOT NULL DEFAULT '',
+		raw bytea NOT NULL,
+		normalized bytea NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + Table + `_created_at_idx ON ` + Table + ` (created_at)`,
+}
+
+// Submission is a stored job submission. Raw is the JSON document that was
+// submitted, and Job is the normalized model.Job that was published.
+// RelaunchedFrom is the invocation ID of the original job if the submission
+// was relaunched.
+type Submission struct {
+	InvocationID   string          `json:"invocation_id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Submitter      string          `json:"submitter"`
+	AppID          string          `json:"app_id"`
+	RelaunchedFrom string          `json:"relaunched_from,omitempty"`
+	Raw            json.RawMessage `json:"submission"`
+	Job            json.RawMessage `json:"job"`
+}
+
+// Store saves and retrieves submissions.
+type Store struct {
+	db        *sqlx.DB
+	retention time.Duration
+}
+
+// New returns a *Store that keeps submissions for the retention period.
+func New(db *sqlx.DB, retention time.Duration) *Store {
+	return &Store{
+		db:        db,
+		retention: retention,
+	}
+}
+
+// NewFromConfig returns a *Store configured by submissions.retention, after
+// creating the table if it doesn't exist. It returns nil unless
+// submissions.enabled is true.
+func NewFromConfig(ctx context.Context, cfg *viper.Viper, conn *sqlx.DB) (*Store, error) {
+	if enabled, err := db.Setup(ctx, cfg, "submissions", conn, schema); !enabled {
+		return nil, err
+	}
+	return New(conn, db.Duration(cfg, "submissions.retention", DefaultRetention)), nil
+}
+
+// Save stores the submitted JSON and the job parsed from it, along with the
+// invocation ID of the job that it relaunches, if any. The submitter is the
+// DE username as it appears in the submission. A submission that's already stored for the invocation ID is kept,
+// since it's the one that was originally sent.
+func (s *Store) Save(context context.Context, raw []byte, job *model.Job, submitter, relaunchedFrom string) error {
+	ctx, span := otel.Tracer(otelName).Start(context, "Save")
+	defer span.End()
+
+	normalized, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	compressedRaw, err := compress(raw)
+	if err != nil {
+		return err
+	}
+
+	compressedJob, err := compress(normalized)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `
+		INSERT INTO ` + Table + ` (invocation_id, submitter, app_id, relaunched_from, raw, normalized)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (invocation_id) DO NOTHING
+	`
+
+	_, err = s.db.ExecContext(ctx, stmt, job.InvocationID, submitter, job.AppID, relaunchedFrom, compressedRaw, compressedJob)
+	return err
+}
+
+// Get returns the submission stored for the invocation ID. It returns
+// ErrNotFound if there isn't one or if it's older than the retention period.
+func (s *Store) Get(context context.Context, invocationID string) (*Submission, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Get")
+	defer span.End()
+
+	const query = `
+		SELECT invocation_id, created_at, submitter, app_id, relaunched_from, raw, normalized
+		FROM ` + Table + `
+		WHERE invocation_id = $1
+		AND created_at >= $2
+	`
+
+	var (
+		sub                 Submission
+		rawData, normalized []byte
+	)
+
+	err := s.db.QueryRowxContext(ctx, query, invocationID, s.cutoff()).
+		Scan(&sub.InvocationID, &sub.CreatedAt, &sub.Submitter, &sub.AppID, &sub.RelaunchedFrom, &rawData, &normalized)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Raw, err = decompress(rawData); err != nil {
+		return nil, err
+	}
+	if sub.Job, err = decompress(normalized); err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// Purge removes the submissions that are older than the retention period and
+// returns the number that were removed.
+func (s *Store) Purge(context context.Context) (int64, error) {
+	ctx, span := otel.Tracer(otelName).Start(context, "Purge")
+	defer span.End()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM `+Table+` WHERE created_at < $1`, s.cutoff())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunPurger calls Purge at every interval until the context is canceled.
+func (s *Store) RunPurger(ctx context.Context, interval time.Duration) {
+	log := log.WithFields(logrus.Fields{"context": "purging submissions"})
+
+	db.RunEvery(ctx, interval, log, func(ctx context.Context) (bool, error) {
+		removed, err := s.Purge(ctx)
+		if removed > 0 {
+			log.Infof("removed %d submissions older than %s", removed, s.retention)
+		}
+		return false, err
+	})
+}
+
+func (s *Store) cutoff() time.Time {
+	return time.Now().Add(-s.retention)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
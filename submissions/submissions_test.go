@@ -0,0 +1,205 @@
+package submissions
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/auth"
+	"github.com/cyverse-de/jex-adapter/logging"
+	"github.com/cyverse-de/jex-adapter/testutil"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invocationID = testutil.InvocationID
+
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	conn, mock := testutil.MockDB(t)
+	return New(conn, time.Hour), mock
+}
+
+// gzipped matches a gzip-compressed argument that decompresses to want.
+type gzipped struct {
+	want []byte
+}
+
+func (g gzipped) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	data, err := decompress(b)
+	return err == nil && string(data) == string(g.want)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	conn, mock := testutil.MockDB(t)
+
+	// Submissions are only stored when it's enabled, so nothing touches the
+	// database otherwise.
+	store, err := NewFromConfig(context.Background(), viper.New(), conn)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	cfg := viper.New()
+	cfg.Set("submissions.enabled", true)
+	cfg.Set("submissions.retention", "2h")
+	testutil.ExpectSchema(mock, schema)
+
+	store, err = NewFromConfig(context.Background(), cfg, conn)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	assert.Equal(t, 2*time.Hour, store.retention)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAndGet(t *testing.T) {
+	store, mock := newMockStore(t)
+	raw, job := testutil.Submission(t)
+
+	normalized, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	mock.ExpectExec("INSERT INTO "+Table).
+		WithArgs(invocationID, "test@this is a test", job.AppID, "", gzipped{raw}, gzipped{normalized}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.Save(context.Background(), raw, job, "test@this is a test", ""))
+
+	compressedRaw, err := compress(raw)
+	require.NoError(t, err)
+	compressedJob, err := compress(normalized)
+	require.NoError(t, err)
+
+	created := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WithArgs(invocationID, testutil.Within(time.Now().Add(-time.Hour))).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}).
+			AddRow(invocationID, created, job.Submitter, job.AppID, "", compressedRaw, compressedJob))
+
+	sub, err := store.Get(context.Background(), invocationID)
+	require.NoError(t, err)
+	assert.Equal(t, created, sub.CreatedAt)
+	assert.Equal(t, string(raw), string(sub.Raw))
+	assert.JSONEq(t, string(normalized), string(sub.Job))
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}))
+	_, err = store.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurge(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectExec("DELETE FROM " + Table).
+		WithArgs(testutil.Within(time.Now().Add(-time.Hour))).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	removed, err := store.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres runs the store against the schema in a real database. It only
+// runs if JEX_ADAPTER_TEST_DATABASE is set.
+func TestPostgres(t *testing.T) {
+	conn := testutil.PostgresDB(t)
+	ctx := context.Background()
+
+	cfg := viper.New()
+	cfg.Set("submissions.enabled", true)
+	store, err := NewFromConfig(ctx, cfg, conn)
+	require.NoError(t, err)
+
+	raw, job := testutil.Submission(t)
+	require.NoError(t, store.Save(ctx, raw, job, "ipcdev@iplantcollaborative.org", ""))
+	require.NoError(t, store.Save(ctx, []byte(`{}`), job, "someone else", ""))
+
+	sub, err := store.Get(ctx, invocationID)
+	require.NoError(t, err)
+	assert.Equal(t, "ipcdev@iplantcollaborative.org", sub.Submitter)
+	assert.Equal(t, job.AppID, sub.AppID)
+	assert.JSONEq(t, string(raw), string(sub.Raw))
+
+	removed, err := store.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	store.retention = -time.Minute
+	removed, err = store.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, err = store.Get(ctx, invocationID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSubmissionHandler(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	e := echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(true, map[string][]auth.Permission{
+		"admin":    {auth.PermAdmin},
+		"launcher": {auth.PermLaunch},
+	}, auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "ops", Key: "admin-key", Roles: []string{"admin"}},
+		{Name: "apps", Key: "launch-key", Roles: []string{"launcher"}},
+	})).Middleware())
+	store.Routes(e.Group("/v1"))
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/jobs/"+invocationID+"/submission", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Launching jobs isn't enough to read the stored submissions.
+	assert.Equal(t, http.StatusForbidden, serve("launch-key").Code)
+
+	// The submission is returned as it was sent, keeping the key order and
+	// the integers that don't fit in a float64.
+	raw := []byte(`{"uuid":"` + invocationID + `","memory_limit":9007199254740993,"environment":{"LANG":"C","AWS_SECRET_ACCESS_KEY":"hunter2"}}`)
+	compressedRaw, err := compress(raw)
+	require.NoError(t, err)
+	compressedJob, err := compress([]byte(`{"uuid":"` + invocationID + `"}`))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}).
+			AddRow(invocationID, time.Now(), "ipcdev", "app", "", compressedRaw, compressedJob))
+
+	rec := serve("admin-key")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Submission json.RawMessage `json:"submission"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, string(raw), string(body.Submission))
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE invocation_id = $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"invocation_id", "created_at", "submitter", "app_id", "relaunched_from", "raw", "normalized"}))
+	assert.Equal(t, http.StatusNotFound, serve("admin-key").Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Submissions aren't served at all when authentication is disabled.
+	e = echo.New()
+	e.HTTPErrorHandler = logging.HTTPErrorHandler
+	e.Use(auth.New(false, nil).Middleware())
+	store.Routes(e.Group("/v1"))
+	assert.Equal(t, http.StatusForbidden, serve("").Code)
+}
@@ -0,0 +1,109 @@
+// Package testutil holds the fixtures shared by the tests of the packages that
+// keep their state in the database, such as the scheduler and the job states.
+package testutil
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cyverse-de/jex-adapter/db"
+	"github.com/cyverse-de/model/v6"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// InvocationID is the invocation ID of the job in the test submission.
+const InvocationID = "07b04ce2-7757-4b21-9e15-0b4c2f44be26"
+
+// Config returns the configuration needed to parse the test submission.
+func Config() *viper.Viper {
+	cfg := viper.New()
+	cfg.Set("condor.log_path", "/tmp/")
+	cfg.Set("condor.filter_files", "output-last-stderr")
+	cfg.Set("irods.base", "/iplant/home")
+	return cfg
+}
+
+// MockDB returns a database backed by sqlmock. It's closed when the test ends.
+func MockDB(t testing.TB) (*sqlx.DB, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return sqlx.NewDb(conn, "postgres"), mock
+}
+
+// PostgresDB returns a connection to the PostgreSQL database at the URL in
+// JEX_ADAPTER_TEST_DATABASE, skipping the test if it isn't set. The connection
+// uses a schema of its own that's dropped when the test ends, so tests can
+// create their tables without interfering with each other.
+func PostgresDB(t testing.TB) *sqlx.DB {
+	url := os.Getenv("JEX_ADAPTER_TEST_DATABASE")
+	if url == "" {
+		t.Skip("JEX_ADAPTER_TEST_DATABASE isn't set")
+	}
+
+	conn, err := sqlx.Connect("postgres", url)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	suffix := make([]byte, 8)
+	_, err = rand.Read(suffix)
+	require.NoError(t, err)
+	schema := "jex_adapter_test_" + hex.EncodeToString(suffix)
+
+	// The search path is set per connection, so the pool is limited to the
+	// one that it's set on.
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	_, err = conn.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = conn.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+	_, err = conn.Exec(`SET search_path TO ` + schema)
+	require.NoError(t, err)
+
+	return conn
+}
+
+// ExpectSchema expects db.EnsureSchema to run exactly the statements, in order.
+func ExpectSchema(mock sqlmock.Sqlmock, stmts []string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(db.SchemaLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, stmt := range stmts {
+		mock.ExpectExec("^" + regexp.QuoteMeta(stmt) + "$").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+}
+
+// Submission returns the test submission and the job parsed from it.
+func Submission(t testing.TB) ([]byte, *model.Job) {
+	_, file, _, _ := runtime.Caller(0)
+	raw, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "test", "test_submission.json"))
+	require.NoError(t, err)
+	job, err := model.NewFromData(Config(), raw)
+	require.NoError(t, err)
+	return raw, job
+}
+
+// Within returns an argument matcher for a time within a minute of want.
+func Within(want time.Time) sqlmock.Argument {
+	return within{want}
+}
+
+type within struct {
+	want time.Time
+}
+
+func (w within) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Sub(w.want).Abs() < time.Minute
+}